@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// addressHashLen is the length of the public key or script hash in an address
+const addressHashLen = 20
+
+// Address encodings
+const (
+	base58Encoding = iota
+	bech32Encoding
+)
+
+// Witness versions of Bech32 addresses: public key hashes use Bech32, script hashes Bech32m
+const (
+	pubKeyHashWitnessVersion = 0
+	scriptHashWitnessVersion = 1
+)
+
+// Network holds the version bytes and Bech32 prefix addresses and private keys of a chain are encoded with
+type Network struct {
+	Name              string
+	PubKeyHashVersion byte
+	ScriptHashVersion byte
+	PrivateKeyVersion byte
+	Bech32HRP         string
+}
+
+// Regtest shares the Base58 version bytes of testnet, as it does in Bitcoin, only its Bech32 prefix differs
+var (
+	mainNet = &Network{"mainnet", 0x00, 0x05, 0x80, "bc"}
+	testNet = &Network{"testnet", 0x6f, 0xc4, 0xef, "tb"}
+	regTest = &Network{"regtest", 0x6f, 0xc4, 0xef, "bcrt"}
+)
+
+var networks = []*Network{mainNet, testNet, regTest}
+
+// activeNetwork is the network addresses are created for and checked against, set from the NETWORK env. var.
+var activeNetwork = mainNet
+
+// NetworkByName returns the network called name, mainnet when name is empty
+func NetworkByName(name string) (*Network, error) {
+	if name == "" {
+		return mainNet, nil
+	}
+
+	for _, network := range networks {
+		if network.Name == strings.ToLower(name) {
+			return network, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Unknown network %q, use mainnet, testnet or regtest", name)
+}
+
+// ParseAddressEncoding returns the address encoding called name
+func ParseAddressEncoding(name string) (int, error) {
+	switch strings.ToLower(name) {
+	case "base58":
+		return base58Encoding, nil
+	case "bech32":
+		return bech32Encoding, nil
+	}
+
+	return 0, fmt.Errorf("Unknown address type %q, use base58 or bech32", name)
+}
+
+// Address is a decoded address: the type of output it locks, the public key or script hash and how it's written
+type Address struct {
+	Network  *Network
+	Type     int
+	Hash     []byte
+	Encoding int
+}
+
+// NewPubKeyHashAddress returns the Base58Check address paying to a public key hash on the active network
+func NewPubKeyHashAddress(pubKeyHash []byte) Address {
+	return Address{activeNetwork, pubKeyHashOutput, pubKeyHash, base58Encoding}
+}
+
+// NewScriptHashAddress returns the Base58Check address paying to a redeem script hash on the active network
+func NewScriptHashAddress(scriptHash []byte) Address {
+	return Address{activeNetwork, scriptHashOutput, scriptHash, base58Encoding}
+}
+
+// DecodeAddress decodes and checks a Base58Check or Bech32 address of the active network
+func DecodeAddress(address string) (Address, error) {
+	lower := strings.ToLower(address)
+	for _, network := range networks {
+		if strings.HasPrefix(lower, network.Bech32HRP+"1") {
+			return decodeBech32Address(address)
+		}
+	}
+
+	version, hash, err := base58CheckDecode(address)
+	if err != nil {
+		return Address{}, fmt.Errorf("Address %s is not valid: %s", address, err)
+	}
+	if len(hash) != addressHashLen {
+		return Address{}, fmt.Errorf("Address %s is not valid: hash must be %d bytes, got %d", address, addressHashLen, len(hash))
+	}
+
+	switch version {
+	case activeNetwork.PubKeyHashVersion:
+		return NewPubKeyHashAddress(hash), nil
+	case activeNetwork.ScriptHashVersion:
+		return NewScriptHashAddress(hash), nil
+	}
+
+	for _, network := range networks {
+		if version == network.PubKeyHashVersion || version == network.ScriptHashVersion {
+			return Address{}, fmt.Errorf("Address %s is for %s, this node is on %s", address, network.Name, activeNetwork.Name)
+		}
+	}
+
+	return Address{}, fmt.Errorf("Address %s has unknown version 0x%02x", address, version)
+}
+
+// decodeBech32Address decodes a Bech32 address, its witness version tells the output type
+func decodeBech32Address(address string) (Address, error) {
+	hrp, data, spec, err := bech32Decode(address)
+	if err != nil {
+		return Address{}, fmt.Errorf("Address %s is not valid: %s", address, err)
+	}
+	if hrp != activeNetwork.Bech32HRP {
+		for _, network := range networks {
+			if hrp == network.Bech32HRP {
+				return Address{}, fmt.Errorf("Address %s is for %s, this node is on %s", address, network.Name, activeNetwork.Name)
+			}
+		}
+
+		return Address{}, fmt.Errorf("Address %s has unknown prefix %s", address, hrp)
+	}
+	if len(data) == 0 {
+		return Address{}, fmt.Errorf("Address %s is not valid: no witness version", address)
+	}
+
+	hash, err := convertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return Address{}, fmt.Errorf("Address %s is not valid: %s", address, err)
+	}
+	if len(hash) != addressHashLen {
+		return Address{}, fmt.Errorf("Address %s is not valid: hash must be %d bytes, got %d", address, addressHashLen, len(hash))
+	}
+
+	var decoded Address
+	switch {
+	case data[0] == pubKeyHashWitnessVersion && spec == bech32Spec:
+		decoded = NewPubKeyHashAddress(hash)
+	case data[0] == scriptHashWitnessVersion && spec == bech32mSpec:
+		decoded = NewScriptHashAddress(hash)
+	default:
+		return Address{}, fmt.Errorf("Address %s has unsupported witness version %d or wrong checksum variant", address, data[0])
+	}
+	decoded.Encoding = bech32Encoding
+
+	return decoded, nil
+}
+
+// Version returns the version byte the address is encoded with
+func (a Address) Version() byte {
+	if a.Type == scriptHashOutput {
+		return a.Network.ScriptHashVersion
+	}
+
+	return a.Network.PubKeyHashVersion
+}
+
+// String encodes the address with Base58Check or Bech32, depending on its encoding
+func (a Address) String() string {
+	if a.Encoding == bech32Encoding {
+		witnessVersion, spec := byte(pubKeyHashWitnessVersion), bech32Spec
+		if a.Type == scriptHashOutput {
+			witnessVersion, spec = scriptHashWitnessVersion, bech32mSpec
+		}
+
+		data, err := convertBits(a.Hash, 8, 5, true)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		return bech32Encode(a.Network.Bech32HRP, append([]byte{witnessVersion}, data...), spec)
+	}
+
+	return base58CheckEncode(a.Version(), a.Hash)
+}
+
+// WithEncoding returns the same address written with encoding
+func (a Address) WithEncoding(encoding int) Address {
+	a.Encoding = encoding
+
+	return a
+}
+
+// Equal checks whether two addresses lock outputs the same way
+func (a Address) Equal(other Address) bool {
+	return a.Type == other.Type && bytes.Compare(a.Hash, other.Hash) == 0
+}
+
+// MarshalText encodes the address as its string, used by JSON
+func (a Address) MarshalText() ([]byte, error) {
+	if a.Network == nil {
+		return nil, errors.New("Address is empty")
+	}
+
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText decodes and checks an address, used by JSON
+func (a *Address) UnmarshalText(text []byte) error {
+	address, err := DecodeAddress(string(text))
+	if err != nil {
+		return err
+	}
+	*a = address
+
+	return nil
+}
+
+// ValidateAddress check if address if valid
+func ValidateAddress(address string) bool {
+	_, err := DecodeAddress(address)
+
+	return err == nil
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"log"
+)
+
+const addressIndexBucket = "addressindex"
+
+// Key prefixes of the address index bucket
+var (
+	// unspent output: prefix, hash, txid, vout -> value
+	addressOutputPrefix = []byte("u")
+	// transaction reference: prefix, hash, height, txid -> change of the address balance
+	addressTxPrefix = []byte("t")
+	// undo data: prefix, block hash -> the unspent output entries the block spent
+	addressUndoPrefix = []byte("r")
+)
+
+var errAddressIndexDisabled = errors.New("Address index is disabled, run reindexaddresses to build it")
+
+// AddressTx is a transaction touching an address and what it did to its balance
+type AddressTx struct {
+	TxID   []byte
+	Height int
+	Amount int
+}
+
+// addressIndexEntry is an index key and value, kept in undo data
+type addressIndexEntry struct {
+	Key   []byte
+	Value []byte
+}
+
+// AddressIndex maps public key and script hashes to their unspent outputs and transactions.
+// It's optional: it only exists once built by Reindex
+type AddressIndex struct {
+	Blockchain *Blockchain
+}
+
+// IsEnabled checks whether the address index has been built
+func (ai AddressIndex) IsEnabled() bool {
+	enabled := false
+
+	err := ai.Blockchain.db.View(func(tx StoreTx) error {
+		enabled = tx.Bucket([]byte(addressIndexBucket)) != nil
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return enabled
+}
+
+// Reindex builds the address index from the genesis block
+func (ai AddressIndex) Reindex() {
+	err := ai.Blockchain.db.Update(func(tx StoreTx) error {
+		err := tx.DeleteBucket([]byte(addressIndexBucket))
+		if err != nil && err != errBucketNotFound {
+			log.Panic(err)
+		}
+
+		_, err = tx.CreateBucket([]byte(addressIndexBucket))
+
+		return err
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	ai.Sync()
+}
+
+// Drop removes the address index
+func (ai AddressIndex) Drop() {
+	err := ai.Blockchain.db.Update(func(tx StoreTx) error {
+		err := tx.DeleteBucket([]byte(addressIndexBucket))
+		if err != nil && err != errBucketNotFound {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// Sync brings an enabled address index up to the tip of the chain
+func (ai AddressIndex) Sync() {
+	if ai.IsEnabled() {
+		syncChainIndex(ai.Blockchain, ai)
+	}
+}
+
+// ConnectBlock indexes the outputs of a block and removes the ones it spends
+func (ai AddressIndex) ConnectBlock(block *Block) {
+	err := ai.Blockchain.db.Update(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(addressIndexBucket))
+		var undo []addressIndexEntry
+
+		for _, transaction := range block.Transactions {
+			amounts := make(map[string]int)
+
+			if !transaction.IsCoinbase() {
+				for _, vin := range transaction.Vin {
+					hash := inputHash(vin)
+					key := addressOutputKey(hash, vin.Txid, vin.Vout)
+					value := b.Get(key)
+					if value == nil {
+						continue
+					}
+
+					undo = append(undo, addressIndexEntry{key, append([]byte{}, value...)})
+					amounts[string(hash)] -= int(binary.BigEndian.Uint64(value))
+					err := b.Delete(key)
+					if err != nil {
+						log.Panic(err)
+					}
+				}
+			}
+
+			for vout, out := range transaction.Vout {
+				if out.IsUnspendable() {
+					continue
+				}
+
+				err := b.Put(addressOutputKey(out.PubKeyHash, transaction.ID, vout), encodeAmount(out.Value))
+				if err != nil {
+					log.Panic(err)
+				}
+				amounts[string(out.PubKeyHash)] += out.Value
+			}
+
+			for hash, amount := range amounts {
+				err := b.Put(addressTxKey([]byte(hash), block.Height, transaction.ID), encodeAmount(amount))
+				if err != nil {
+					log.Panic(err)
+				}
+			}
+		}
+
+		err := b.Put(addressUndoKey(block.Hash), serializeIndexEntries(undo))
+		if err != nil {
+			log.Panic(err)
+		}
+
+		return b.Put([]byte("l"), block.Hash)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// DisconnectBlock undoes ConnectBlock for a block that left the main chain
+func (ai AddressIndex) DisconnectBlock(block *Block) {
+	err := ai.Blockchain.db.Update(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(addressIndexBucket))
+
+		// The spent outputs come back first: some were created by the block itself and go again below
+		undoKey := addressUndoKey(block.Hash)
+		for _, entry := range deserializeIndexEntries(b.Get(undoKey)) {
+			err := b.Put(entry.Key, entry.Value)
+			if err != nil {
+				log.Panic(err)
+			}
+		}
+
+		err := b.Delete(undoKey)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		for _, transaction := range block.Transactions {
+			hashes := make(map[string]bool)
+			for vout, out := range transaction.Vout {
+				if out.IsUnspendable() {
+					continue
+				}
+				hashes[string(out.PubKeyHash)] = true
+
+				err := b.Delete(addressOutputKey(out.PubKeyHash, transaction.ID, vout))
+				if err != nil {
+					log.Panic(err)
+				}
+			}
+			if !transaction.IsCoinbase() {
+				for _, vin := range transaction.Vin {
+					hashes[string(inputHash(vin))] = true
+				}
+			}
+
+			for hash := range hashes {
+				err := b.Delete(addressTxKey([]byte(hash), block.Height, transaction.ID))
+				if err != nil {
+					log.Panic(err)
+				}
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			return b.Delete([]byte("l"))
+		}
+
+		return b.Put([]byte("l"), block.PrevBlockHash)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// Balance returns the sum of the unspent outputs locked with hash
+func (ai AddressIndex) Balance(hash []byte) (int, error) {
+	balance := 0
+
+	err := ai.Blockchain.db.View(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(addressIndexBucket))
+		if b == nil {
+			return errAddressIndexDisabled
+		}
+
+		prefix := append(append([]byte{}, addressOutputPrefix...), hash...)
+		c := b.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			balance += int(binary.BigEndian.Uint64(v))
+		}
+
+		return nil
+	})
+
+	return balance, err
+}
+
+// UnspentOutputs returns the chainstate keys of the unspent outputs locked with hash
+func (ai AddressIndex) UnspentOutputs(hash []byte) ([][]byte, error) {
+	var keys [][]byte
+
+	err := ai.Blockchain.db.View(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(addressIndexBucket))
+		if b == nil {
+			return errAddressIndexDisabled
+		}
+
+		// The txid and output index end the key as they make up a chainstate key
+		prefix := append(append([]byte{}, addressOutputPrefix...), hash...)
+		c := b.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			keys = append(keys, append([]byte{}, k[len(prefix):]...))
+		}
+
+		return nil
+	})
+
+	return keys, err
+}
+
+// History returns the transactions touching hash in chain order
+func (ai AddressIndex) History(hash []byte) ([]AddressTx, error) {
+	var history []AddressTx
+
+	err := ai.Blockchain.db.View(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(addressIndexBucket))
+		if b == nil {
+			return errAddressIndexDisabled
+		}
+
+		prefix := append(append([]byte{}, addressTxPrefix...), hash...)
+		c := b.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			rest := k[len(prefix):]
+			history = append(history, AddressTx{
+				TxID:   append([]byte{}, rest[4:]...),
+				Height: int(binary.BigEndian.Uint32(rest[:4])),
+				Amount: int(int64(binary.BigEndian.Uint64(v))),
+			})
+		}
+
+		return nil
+	})
+
+	return history, err
+}
+
+func (ai AddressIndex) syncedHash() []byte {
+	return indexTip(ai.Blockchain.db, addressIndexBucket)
+}
+
+// inputHash returns the hash of the output an input spends: its redeem script hash or public key hash
+func inputHash(vin TXInput) []byte {
+	if vin.RedeemScript != nil {
+		return HashPubKey(vin.RedeemScript)
+	}
+
+	return HashPubKey(vin.PubKey)
+}
+
+func addressOutputKey(hash, txID []byte, vout int) []byte {
+	key := append(append([]byte{}, addressOutputPrefix...), hash...)
+	key = append(key, txID...)
+
+	return binary.BigEndian.AppendUint32(key, uint32(vout))
+}
+
+// addressTxKey puts the height before the txid, so the references of an address are sorted by height
+func addressTxKey(hash []byte, height int, txID []byte) []byte {
+	key := append(append([]byte{}, addressTxPrefix...), hash...)
+	key = binary.BigEndian.AppendUint32(key, uint32(height))
+
+	return append(key, txID...)
+}
+
+func addressUndoKey(blockHash []byte) []byte {
+	return append(append([]byte{}, addressUndoPrefix...), blockHash...)
+}
+
+func encodeAmount(amount int) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(int64(amount)))
+}
+
+func serializeIndexEntries(entries []addressIndexEntry) []byte {
+	var encoded bytes.Buffer
+
+	enc := gob.NewEncoder(&encoded)
+	err := enc.Encode(entries)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return encoded.Bytes()
+}
+
+func deserializeIndexEntries(data []byte) []addressIndexEntry {
+	var entries []addressIndexEntry
+	if data == nil {
+		return entries
+	}
+
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	err := dec.Decode(&entries)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return entries
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressTxKeyOrder(t *testing.T) {
+	hash := bytes.Repeat([]byte{0x01}, addressHashLen)
+	txID := bytes.Repeat([]byte{0xff}, 32)
+
+	low := addressTxKey(hash, 2, txID)
+	high := addressTxKey(hash, 256, []byte{0x00})
+
+	assert.Equal(t, -1, bytes.Compare(low, high), "references must sort by height")
+	assert.True(t, bytes.HasPrefix(low, append(addressTxPrefix, hash...)))
+}
+
+func TestAddressIndexConnectDisconnect(t *testing.T) {
+	sender := NewWallet()
+	receiver := NewWallet()
+	third := NewWallet()
+	bc := newTestBlockchain(t, sender.Address())
+	genesis := bc.tip
+	UTXOSet := UTXOSet{bc}
+	UTXOSet.Reindex()
+	index := AddressIndex{bc}
+	index.Reindex()
+
+	// The receiver spends its payment in the same block, the index doesn't check signatures
+	selector, _ := GetCoinSelector(defaultCoinSelection)
+	payment := NewUTXOTransaction([]*Wallet{sender}, []Recipient{{receiver.Address(), 4}}, sender.Address(), selector, 0, sequenceFinal, &UTXOSet)
+	spend := &Transaction{Vin: []TXInput{{Txid: payment.ID, Vout: 0, PubKey: receiver.PublicKey}}, Vout: []TXOutput{*NewTXOutput(4, third.Address())}}
+	spend.ID = spend.Hash()
+	block := NewBlock([]*Transaction{NewCoinbaseTX(sender.Address(), ""), payment, spend}, genesis, 1)
+
+	balance := func(wallet *Wallet) int {
+		balance, err := index.Balance(wallet.Address().Hash)
+		assert.Nil(t, err)
+		return balance
+	}
+
+	index.ConnectBlock(block)
+	assert.Equal(t, 2*subsidy-4, balance(sender))
+	assert.Equal(t, 0, balance(receiver))
+	assert.Equal(t, 4, balance(third))
+	history, err := index.History(receiver.Address().Hash)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []AddressTx{{payment.ID, 1, 4}, {spend.ID, 1, -4}}, history)
+
+	index.DisconnectBlock(block)
+	assert.Equal(t, subsidy, balance(sender))
+	assert.Equal(t, 0, balance(receiver), "Outputs spent in the block they were created in are gone")
+	assert.Equal(t, 0, balance(third))
+	history, err = index.History(receiver.Address().Hash)
+	assert.Nil(t, err)
+	assert.Empty(t, history)
+	assert.Equal(t, genesis, index.syncedHash())
+}
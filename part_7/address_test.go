@@ -0,0 +1,153 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeAddress(t *testing.T) {
+	wallet := NewWallet()
+	encoded := wallet.Address().String()
+
+	address, err := DecodeAddress(encoded)
+	assert.Nil(t, err)
+	assert.True(t, address.Equal(wallet.Address()))
+	assert.Equal(t, pubKeyHashOutput, address.Type)
+	assert.Equal(t, encoded, address.String(), "Address survives decoding")
+
+	script, err := NewMultisigScript(1, [][]byte{wallet.PublicKey})
+	assert.Nil(t, err)
+	address, err = DecodeAddress(string(script.GetAddress()))
+	assert.Nil(t, err)
+	assert.Equal(t, scriptHashOutput, address.Type, "Script hash version is recognized")
+
+	_, err = DecodeAddress(encoded[:len(encoded)-1] + "0")
+	assert.NotNil(t, err, "0 is not a Base58 character")
+	_, err = DecodeAddress(encoded[:len(encoded)-1])
+	assert.NotNil(t, err, "Truncated addresses are rejected")
+	_, err = DecodeAddress(base58CheckEncode(mainNet.PubKeyHashVersion, []byte{1, 2, 3}))
+	assert.NotNil(t, err, "Hash must be 20 bytes")
+	_, err = DecodeAddress("")
+	assert.NotNil(t, err)
+}
+
+func TestAddressNetworks(t *testing.T) {
+	defer func() { activeNetwork = mainNet }()
+
+	wallet := NewWallet()
+	mainnetAddress := wallet.Address().String()
+
+	activeNetwork = testNet
+	testnetAddress := wallet.Address().String()
+	assert.NotEqual(t, mainnetAddress, testnetAddress)
+
+	_, err := DecodeAddress(mainnetAddress)
+	assert.NotNil(t, err, "Mainnet addresses are rejected on testnet")
+	_, err = DecodeAddress(testnetAddress)
+	assert.Nil(t, err)
+
+	wif := wallet.ExportPrivateKey()
+	activeNetwork = mainNet
+	_, err = DecodeAddress(testnetAddress)
+	assert.NotNil(t, err, "Testnet addresses are rejected on mainnet")
+	_, err = NewWalletFromPrivateKey(wif)
+	assert.NotNil(t, err, "Testnet keys are rejected on mainnet")
+
+	network, err := NetworkByName("RegTest")
+	assert.Nil(t, err)
+	assert.Equal(t, regTest, network)
+	_, err = NetworkByName("signet")
+	assert.NotNil(t, err)
+}
+
+func TestBech32(t *testing.T) {
+	valid := map[string]int{
+		"A12UEL5L": bech32Spec,
+		"a12uel5l": bech32Spec,
+		"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw": bech32Spec,
+		"A1LQFN3A": bech32mSpec,
+		"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx": bech32mSpec,
+	}
+	for input, spec := range valid {
+		_, _, decodedSpec, err := bech32Decode(input)
+		assert.Nil(t, err, input)
+		assert.Equal(t, spec, decodedSpec, input)
+	}
+
+	for _, input := range []string{"A12uEL5L", "a12uel5m", "1nwldj5", "abc1rzg", "pzry9x0s0muk"} {
+		_, _, _, err := bech32Decode(input)
+		assert.NotNil(t, err, input)
+	}
+}
+
+func TestBech32Address(t *testing.T) {
+	wallet := NewWallet()
+	address := wallet.Address().WithEncoding(bech32Encoding)
+	encoded := address.String()
+	assert.Regexp(t, "^bc1q", encoded, "Public key hashes are witness version 0")
+
+	decoded, err := DecodeAddress(encoded)
+	assert.Nil(t, err)
+	assert.True(t, decoded.Equal(wallet.Address()), "Both encodings lock to the same hash")
+	assert.Equal(t, bech32Encoding, decoded.Encoding)
+	assert.Equal(t, encoded, decoded.String())
+
+	upper, err := DecodeAddress(strings.ToUpper(encoded))
+	assert.Nil(t, err, "Bech32 is case insensitive")
+	assert.True(t, upper.Equal(decoded))
+
+	script, err := NewMultisigScript(1, [][]byte{wallet.PublicKey})
+	assert.Nil(t, err)
+	scriptAddress := script.Address().WithEncoding(bech32Encoding).String()
+	assert.Regexp(t, "^bc1p", scriptAddress, "Script hashes are witness version 1")
+	decoded, err = DecodeAddress(scriptAddress)
+	assert.Nil(t, err)
+	assert.Equal(t, scriptHashOutput, decoded.Type)
+
+	typo := []byte(encoded)
+	typo[len(typo)-3] = 'q'
+	if typo[len(typo)-3] == encoded[len(encoded)-3] {
+		typo[len(typo)-3] = 'p'
+	}
+	_, err = DecodeAddress(string(typo))
+	assert.NotNil(t, err, "Checksum catches typos")
+
+	defer func() { activeNetwork = mainNet }()
+	activeNetwork = regTest
+	_, err = DecodeAddress(encoded)
+	assert.NotNil(t, err, "Mainnet prefix is rejected on regtest")
+	assert.Regexp(t, "^bcrt1q", wallet.Address().WithEncoding(bech32Encoding).String())
+}
+
+func TestWalletsFindAddress(t *testing.T) {
+	wallets := Wallets{Wallets: make(map[string]*Wallet), Scripts: make(map[string]*MultisigScript), Watched: make(map[string][]byte)}
+	address := wallets.CreateWallet(bech32Encoding)
+	wallet := wallets.Wallets[address]
+
+	key, ok := wallets.FindAddress(wallet.Address().WithEncoding(base58Encoding))
+	assert.True(t, ok, "Wallet addresses are found in either encoding")
+	assert.Equal(t, address, key)
+}
+
+func TestNetworkIsRecorded(t *testing.T) {
+	dir, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(dir)
+	defer func() { activeNetwork = mainNet }()
+
+	bc := newTestBlockchain(t, NewWallet().Address())
+	wallets, _ := NewWallets("test")
+	wallets.CreateWallet(base58Encoding)
+	wallets.SaveToFile("test")
+
+	bc.checkNetwork()
+	_, err := NewWallets("test")
+	assert.Nil(t, err)
+
+	activeNetwork = testNet
+	assert.Panics(t, bc.checkNetwork, "A mainnet chain can't be opened on testnet")
+	assert.Panics(t, func() { NewWallets("test") }, "A mainnet wallet can't be opened on testnet")
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"math/big"
 )
 
@@ -23,7 +25,7 @@ func Base58Encode(input []byte) []byte {
 	}
 
 	ReverseBytes(result)
-	for b := range input {
+	for _, b := range input {
 		if b == 0x00 {
 			result = append([]byte{b58Alphabet[0]}, result...)
 		} else {
@@ -34,20 +36,25 @@ func Base58Encode(input []byte) []byte {
 	return result
 }
 
-// Base58Decode decodes Base58-encoded data
-func Base58Decode(input []byte) []byte {
+// Base58Decode decodes Base58-encoded data, it fails on characters outside the alphabet
+func Base58Decode(input []byte) ([]byte, error) {
 	result := big.NewInt(0)
 	zeroBytes := 0
 
-	for b := range input {
-		if b == 0x00 {
+	for _, b := range input {
+		if b == b58Alphabet[0] {
 			zeroBytes++
+		} else {
+			break
 		}
 	}
 
 	payload := input[zeroBytes:]
 	for _, b := range payload {
 		charIndex := bytes.IndexByte(b58Alphabet, b)
+		if charIndex < 0 {
+			return nil, fmt.Errorf("Invalid Base58 character %q", b)
+		}
 		result.Mul(result, big.NewInt(58))
 		result.Add(result, big.NewInt(int64(charIndex)))
 	}
@@ -55,5 +62,31 @@ func Base58Decode(input []byte) []byte {
 	decoded := result.Bytes()
 	decoded = append(bytes.Repeat([]byte{byte(0x00)}, zeroBytes), decoded...)
 
-	return decoded
+	return decoded, nil
+}
+
+// base58CheckEncode encodes a version byte and payload followed by their checksum
+func base58CheckEncode(version byte, payload []byte) string {
+	versionedPayload := append([]byte{version}, payload...)
+	fullPayload := append(versionedPayload, checksum(versionedPayload)...)
+
+	return string(Base58Encode(fullPayload))
+}
+
+// base58CheckDecode decodes data encoded by base58CheckEncode, verifying its checksum
+func base58CheckDecode(input string) (byte, []byte, error) {
+	decoded, err := Base58Decode([]byte(input))
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(decoded) < 1+addressChecksumLen {
+		return 0, nil, errors.New("Too short")
+	}
+
+	versionedPayload := decoded[:len(decoded)-addressChecksumLen]
+	if bytes.Compare(checksum(versionedPayload), decoded[len(decoded)-addressChecksumLen:]) != 0 {
+		return 0, nil, errors.New("Checksum doesn't match")
+	}
+
+	return versionedPayload[0], versionedPayload[1:], nil
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// Checksum variants: Bech32 (BIP173) and Bech32m (BIP350)
+const (
+	bech32Spec = iota
+	bech32mSpec
+)
+
+const bech32mConst = 0x2bc830a3
+
+// bech32MaxLen is the longest string Bech32 errors are guaranteed to be detected on
+const bech32MaxLen = 90
+
+var bech32Generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+func bech32Polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= bech32Generator[i]
+			}
+		}
+	}
+
+	return chk
+}
+
+// bech32HRPExpand spreads the human-readable part over 5-bit values for the checksum
+func bech32HRPExpand(hrp string) []byte {
+	var expanded []byte
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+
+	return expanded
+}
+
+func bech32Checksum(hrp string, data []byte, spec int) []byte {
+	constant := uint32(1)
+	if spec == bech32mSpec {
+		constant = bech32mConst
+	}
+
+	values := append(bech32HRPExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	polymod := bech32Polymod(values) ^ constant
+
+	checksum := make([]byte, 6)
+	for i := range checksum {
+		checksum[i] = byte((polymod >> uint(5*(5-i))) & 31)
+	}
+
+	return checksum
+}
+
+// bech32Encode encodes 5-bit data under the human-readable part hrp
+func bech32Encode(hrp string, data []byte, spec int) string {
+	combined := append(data, bech32Checksum(hrp, data, spec)...)
+
+	var result strings.Builder
+	result.WriteString(hrp)
+	result.WriteByte('1')
+	for _, v := range combined {
+		result.WriteByte(bech32Charset[v])
+	}
+
+	return result.String()
+}
+
+// bech32Decode decodes a Bech32 or Bech32m string into its human-readable part and 5-bit data
+func bech32Decode(input string) (string, []byte, int, error) {
+	if len(input) > bech32MaxLen {
+		return "", nil, 0, fmt.Errorf("Longer than %d characters", bech32MaxLen)
+	}
+	if strings.ToLower(input) != input && strings.ToUpper(input) != input {
+		return "", nil, 0, errors.New("Mixed case")
+	}
+	input = strings.ToLower(input)
+
+	separator := strings.LastIndexByte(input, '1')
+	if separator < 1 || separator+7 > len(input) {
+		return "", nil, 0, errors.New("Separator is misplaced")
+	}
+
+	hrp := input[:separator]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, 0, fmt.Errorf("Invalid character in prefix %q", hrp[i])
+		}
+	}
+
+	var data []byte
+	for i := separator + 1; i < len(input); i++ {
+		v := strings.IndexByte(bech32Charset, input[i])
+		if v < 0 {
+			return "", nil, 0, fmt.Errorf("Invalid Bech32 character %q", input[i])
+		}
+		data = append(data, byte(v))
+	}
+
+	var spec int
+	switch bech32Polymod(append(bech32HRPExpand(hrp), data...)) {
+	case 1:
+		spec = bech32Spec
+	case bech32mConst:
+		spec = bech32mSpec
+	default:
+		return "", nil, 0, errors.New("Checksum doesn't match")
+	}
+
+	return hrp, data[:len(data)-6], spec, nil
+}
+
+// convertBits regroups data from fromBits-bit to toBits-bit values
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var result []byte
+	acc := uint32(0)
+	bits := uint(0)
+	maxValue := uint32(1)<<toBits - 1
+
+	for _, v := range data {
+		if uint32(v)>>fromBits != 0 {
+			return nil, errors.New("Value out of range")
+		}
+		acc = acc<<fromBits | uint32(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			result = append(result, byte(acc>>bits&maxValue))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			result = append(result, byte(acc<<(toBits-bits)&maxValue))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxValue != 0 {
+		return nil, errors.New("Invalid padding")
+	}
+
+	return result, nil
+}
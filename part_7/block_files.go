@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Blocks are appended to blkNNNNN.dat files in blockFilesDir, a new file is started past maxBlockFileSize
+const blockFilesDir = "blocks_%s"
+const blockFileName = "blk%05d.dat"
+const maxBlockFileSize = 128 << 20
+
+// Every block in a file is preceded by blockFileMagic and its length, so the files can be read without the index
+var blockFileMagic = []byte{0xf9, 0xbe, 0xb4, 0xd9}
+
+// blockPos is where a serialized block is stored in the block files
+type blockPos struct {
+	File   int
+	Offset int64
+	Length int
+}
+
+// blockFiles appends blocks to the block files of a node and reads them back
+type blockFiles struct {
+	dir string
+	mu  sync.Mutex
+	// current is the file blocks are appended to
+	current int
+	// maxSize is the size past which a new file is started
+	maxSize int64
+}
+
+// openBlockFiles opens the block files in dir, creating it if needed
+func openBlockFiles(dir string) (*blockFiles, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	files := &blockFiles{dir: dir, maxSize: maxBlockFileSize}
+	numbers, err := files.Numbers()
+	if err != nil {
+		return nil, err
+	}
+	if len(numbers) > 0 {
+		files.current = numbers[len(numbers)-1]
+	}
+
+	return files, nil
+}
+
+// Numbers returns the numbers of the block files on disk in order
+func (f *blockFiles) Numbers() ([]int, error) {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var numbers []int
+	for _, entry := range entries {
+		var number int
+		if _, err := fmt.Sscanf(entry.Name(), blockFileName, &number); err == nil {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Ints(numbers)
+
+	return numbers, nil
+}
+
+func (f *blockFiles) path(number int) string {
+	return filepath.Join(f.dir, fmt.Sprintf(blockFileName, number))
+}
+
+// Write appends a serialized block to the current file, moving on to a new file when it's full
+func (f *blockFiles) Write(data []byte) (blockPos, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	record := len(blockFileMagic) + 4 + len(data)
+	if info, err := os.Stat(f.path(f.current)); err == nil && info.Size() > 0 && info.Size()+int64(record) > f.maxSize {
+		f.current++
+	}
+
+	file, err := os.OpenFile(f.path(f.current), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return blockPos{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return blockPos{}, err
+	}
+
+	var buff bytes.Buffer
+	buff.Write(blockFileMagic)
+	binary.Write(&buff, binary.LittleEndian, uint32(len(data)))
+	buff.Write(data)
+
+	_, err = file.Write(buff.Bytes())
+	if err != nil {
+		return blockPos{}, err
+	}
+
+	// The block must be on disk before the index points to it
+	err = file.Sync()
+	if err != nil {
+		return blockPos{}, err
+	}
+
+	return blockPos{f.current, info.Size() + int64(len(blockFileMagic)+4), len(data)}, nil
+}
+
+// Read returns the serialized block at pos
+func (f *blockFiles) Read(pos blockPos) ([]byte, error) {
+	file, err := os.Open(f.path(pos.File))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data := make([]byte, pos.Length)
+	_, err = file.ReadAt(data, pos.Offset)
+	if err == io.EOF {
+		return nil, errors.New("Block file is truncated")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// Size returns the size of a block file
+func (f *blockFiles) Size(number int) (int64, error) {
+	info, err := os.Stat(f.path(number))
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size(), nil
+}
+
+// Remove deletes a block file, the file blocks are appended to can't be removed
+func (f *blockFiles) Remove(number int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if number == f.current {
+		return errors.New("Can't remove the block file in use")
+	}
+
+	return os.Remove(f.path(number))
+}
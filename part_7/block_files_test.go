@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockFiles(t *testing.T) {
+	dir := t.TempDir()
+	files, err := openBlockFiles(dir)
+	assert.Nil(t, err)
+
+	first, err := files.Write([]byte("first block"))
+	assert.Nil(t, err)
+	second, err := files.Write([]byte("second"))
+	assert.Nil(t, err)
+	assert.Equal(t, first.File, second.File)
+	assert.True(t, second.Offset > first.Offset+int64(first.Length), "Blocks are framed by magic and length")
+
+	reopened, err := openBlockFiles(dir)
+	assert.Nil(t, err)
+	data, err := reopened.Read(first)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("first block"), data)
+	data, err = reopened.Read(second)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("second"), data)
+
+	_, err = reopened.Read(blockPos{second.File, second.Offset, 100})
+	assert.NotNil(t, err, "Reads past the end fail")
+}
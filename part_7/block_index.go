@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"log"
+)
+
+// blockIndexBucket maps block hashes to where the blocks are stored in the block files, and "l" to the tip
+const blockIndexBucket = "blockindex"
+
+// Status flags of a block index entry
+const (
+	// blockHaveData is set while the body of the block is in the block files
+	blockHaveData = 1 << iota
+)
+
+// BlockHeader is a block without its transactions
+type BlockHeader struct {
+	Timestamp     int64
+	PrevBlockHash []byte
+	Hash          []byte
+	Nonce         int
+	Height        int
+	// TxHash is the merkle root of the transactions, the proof of work covers it
+	TxHash []byte
+}
+
+// Header returns the header of the block
+func (b *Block) Header() BlockHeader {
+	return BlockHeader{b.Timestamp, b.PrevBlockHash, b.Hash, b.Nonce, b.Height, b.HashTransactions()}
+}
+
+// blockIndexEntry is what the block index knows about a block
+type blockIndexEntry struct {
+	Pos    blockPos
+	Header BlockHeader
+	Status int
+}
+
+func (e blockIndexEntry) serialize() []byte {
+	var encoded bytes.Buffer
+
+	enc := gob.NewEncoder(&encoded)
+	err := enc.Encode(e)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return encoded.Bytes()
+}
+
+func deserializeBlockIndexEntry(data []byte) blockIndexEntry {
+	var entry blockIndexEntry
+
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	err := dec.Decode(&entry)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return entry
+}
+
+// writeBlock appends a block to the block files and adds it to the block index of tx
+func (bc *Blockchain) writeBlock(tx StoreTx, block *Block) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(blockIndexBucket))
+	if err != nil {
+		return err
+	}
+
+	pos, err := bc.blocks.Write(block.Serialize())
+	if err != nil {
+		return err
+	}
+
+	return b.Put(block.Hash, blockIndexEntry{pos, block.Header(), blockHaveData}.serialize())
+}
+
+// getBlockIndexEntry returns the block index entry of a block
+func (bc *Blockchain) getBlockIndexEntry(blockHash []byte) (blockIndexEntry, error) {
+	var entry blockIndexEntry
+
+	err := bc.db.View(func(tx StoreTx) error {
+		data := tx.Bucket([]byte(blockIndexBucket)).Get(blockHash)
+		if data == nil {
+			return errors.New("Block is not found.")
+		}
+		entry = deserializeBlockIndexEntry(data)
+
+		return nil
+	})
+
+	return entry, err
+}
+
+// GetBlockHeader returns the header of a block without reading the block files
+func (bc *Blockchain) GetBlockHeader(blockHash []byte) (BlockHeader, error) {
+	entry, err := bc.getBlockIndexEntry(blockHash)
+
+	return entry.Header, err
+}
+
+// needsBlock tells if a block offered by a peer is worth downloading: it's unknown, or only its header is known
+// while the node checks the UTXO snapshot it started from. Pruned blocks aren't downloaded again
+func (bc *Blockchain) needsBlock(blockHash []byte) bool {
+	entry, err := bc.getBlockIndexEntry(blockHash)
+	if err != nil {
+		return true
+	}
+
+	return entry.Status&blockHaveData == 0 && bc.SnapshotPending()
+}
+
+// migrateBlocks moves the blocks of a database from before the block files from the blocks bucket to the files
+func (bc *Blockchain) migrateBlocks() {
+	err := bc.db.Update(func(tx StoreTx) error {
+		old := tx.Bucket([]byte(blocksBucket))
+		if old == nil || tx.Bucket([]byte(blockIndexBucket)) != nil {
+			return nil
+		}
+
+		b, err := tx.CreateBucket([]byte(blockIndexBucket))
+		if err != nil {
+			return err
+		}
+
+		err = old.ForEach(func(k, v []byte) error {
+			if bytes.Compare(k, []byte("l")) == 0 {
+				return b.Put(k, v)
+			}
+
+			return bc.writeBlock(tx, DeserializeBlock(v))
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.DeleteBucket([]byte(blocksBucket))
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
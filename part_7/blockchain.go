@@ -8,83 +8,123 @@ import (
 	"fmt"
 	"log"
 	"os"
-
-	"github.com/boltdb/bolt"
+	"time"
 )
 
 const dbFile = "blockchain_%s.db"
+
+// blocksBucket held whole blocks before they moved to the block files, it's only read to migrate older databases
 const blocksBucket = "blocks"
+
+// networkKey holds in the block index the name of the network the chain was created on
+var networkKey = []byte("n")
+
 const genesisCoinbaseData = "The Times 03/Jan/2009 Chancellor on brink of second bailout for banks"
 
 // Blockchain implements interactions with a DB
 type Blockchain struct {
-	tip []byte
-	db  *bolt.DB
+	tip    []byte
+	db     Store
+	blocks *blockFiles
+	// utxoCache holds UTXO set changes not written to the chainstate bucket yet, nodes set it
+	utxoCache *utxoCache
+	// events is the WebSocket feed of a node, nil elsewhere
+	events *eventFeed
 }
 
 // CreateBlockchain creates a new blockchain DB
-func CreateBlockchain(address, nodeID string) *Blockchain {
-	dbFile := fmt.Sprintf(dbFile, nodeID)
-	if dbExists(dbFile) {
+func CreateBlockchain(address Address, nodeID string) *Blockchain {
+	if storeExists(nodeID) {
 		fmt.Println("Blockchain already exists.")
 		os.Exit(1)
 	}
 
-	var tip []byte
-
-	cbtx := NewCoinbaseTX(address, genesisCoinbaseData)
-	genesis := NewGenesisBlock(cbtx)
-
-	db, err := bolt.Open(dbFile, 0600, nil)
+	db, err := openStore(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	blocks, err := openBlockFiles(fmt.Sprintf(blockFilesDir, nodeID))
 	if err != nil {
 		log.Panic(err)
 	}
 
-	err = db.Update(func(tx *bolt.Tx) error {
-		b, err := tx.CreateBucket([]byte(blocksBucket))
+	return createBlockchainInStore(db, blocks, address)
+}
+
+// createBlockchainInStore writes a genesis block paying address to an empty store and block files
+func createBlockchainInStore(db Store, blocks *blockFiles, address Address) *Blockchain {
+	bc := Blockchain{db: db, blocks: blocks}
+
+	cbtx := NewCoinbaseTX(address, genesisCoinbaseData)
+	genesis := NewGenesisBlock(cbtx)
+
+	err := db.Update(func(tx StoreTx) error {
+		err := bc.writeBlock(tx, genesis)
 		if err != nil {
 			log.Panic(err)
 		}
 
-		err = b.Put(genesis.Hash, genesis.Serialize())
+		err = tx.Bucket([]byte(blockIndexBucket)).Put([]byte("l"), genesis.Hash)
 		if err != nil {
 			log.Panic(err)
 		}
+		bc.tip = genesis.Hash
 
-		err = b.Put([]byte("l"), genesis.Hash)
+		err = tx.Bucket([]byte(blockIndexBucket)).Put(networkKey, []byte(activeNetwork.Name))
 		if err != nil {
 			log.Panic(err)
 		}
-		tip = genesis.Hash
 
-		return nil
+		_, err = tx.CreateBucket([]byte(txIndexBucket))
+
+		return err
 	})
 	if err != nil {
 		log.Panic(err)
 	}
 
-	bc := Blockchain{tip, db}
+	bc.syncIndexes()
 
 	return &bc
 }
 
-// NewBlockchain creates a new Blockchain with genesis Block
+// NewBlockchain opens the blockchain of a node. An interrupted reindex of the UTXO set is finished first,
+// the UTXO set is incomplete until then
 func NewBlockchain(nodeID string) *Blockchain {
-	dbFile := fmt.Sprintf(dbFile, nodeID)
-	if dbExists(dbFile) == false {
+	bc := openBlockchain(nodeID)
+
+	UTXOSet := UTXOSet{bc}
+	if UTXOSet.IsReindexing() {
+		fmt.Println("Resuming the interrupted reindex of the UTXO set")
+		UTXOSet.ReindexWithProgress(printReindexProgress)
+		fmt.Println()
+	}
+
+	return bc
+}
+
+// openBlockchain opens the blockchain of a node, even while its UTXO set is being reindexed
+func openBlockchain(nodeID string) *Blockchain {
+	if storeExists(nodeID) == false {
 		fmt.Println("No existing blockchain found. Create one first.")
 		os.Exit(1)
 	}
 
-	var tip []byte
-	db, err := bolt.Open(dbFile, 0600, nil)
+	db, err := openStore(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	blocks, err := openBlockFiles(fmt.Sprintf(blockFilesDir, nodeID))
 	if err != nil {
 		log.Panic(err)
 	}
 
-	err = db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
-		tip = b.Get([]byte("l"))
+	bc := Blockchain{db: db, blocks: blocks}
+	bc.migrateBlocks()
+
+	err = db.View(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(blockIndexBucket))
+		bc.tip = append(bc.tip, b.Get([]byte("l"))...)
 
 		return nil
 	})
@@ -92,36 +132,74 @@ func NewBlockchain(nodeID string) *Blockchain {
 		log.Panic(err)
 	}
 
-	bc := Blockchain{tip, db}
+	bc.checkNetwork()
+	bc.syncIndexes()
+	UTXOSet{&bc}.migrate()
 
 	return &bc
 }
 
+// checkNetwork refuses to open a chain created on another network than the active one.
+// Chains created before the network was recorded are taken to be on the active network
+func (bc *Blockchain) checkNetwork() {
+	var network []byte
+
+	err := bc.db.Update(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(blockIndexBucket))
+		network = append(network, b.Get(networkKey)...)
+		if len(network) > 0 {
+			return nil
+		}
+
+		network = []byte(activeNetwork.Name)
+		return b.Put(networkKey, network)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if string(network) != activeNetwork.Name {
+		log.Panicf("ERROR: The blockchain is on %s, this node is on %s. Set NETWORK=%s", network, activeNetwork.Name, network)
+	}
+}
+
 // AddBlock saves the block into the blockchain
 func (bc *Blockchain) AddBlock(block *Block) {
-	err := bc.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
+	var oldTip []byte
+
+	err := bc.db.Update(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(blockIndexBucket))
 		blockInDb := b.Get(block.Hash)
 
 		if blockInDb != nil {
-			return nil
+			// Blocks only known by their header, like the ones below a UTXO snapshot, get their data back
+			entry := deserializeBlockIndexEntry(blockInDb)
+			if entry.Status&blockHaveData != 0 {
+				return nil
+			}
+			if bytes.Compare(block.HashTransactions(), entry.Header.TxHash) != 0 {
+				fmt.Printf("Block %x doesn't match its header, dropped\n", block.Hash)
+				return nil
+			}
+
+			return bc.writeBlock(tx, block)
 		}
 
-		blockData := block.Serialize()
-		err := b.Put(block.Hash, blockData)
+		err := bc.writeBlock(tx, block)
 		if err != nil {
 			log.Panic(err)
 		}
 
 		lastHash := b.Get([]byte("l"))
-		lastBlockData := b.Get(lastHash)
-		lastBlock := DeserializeBlock(lastBlockData)
+		lastBlock := deserializeBlockIndexEntry(b.Get(lastHash)).Header
 
-		if block.Height > lastBlock.Height {
+		// A block whose parent hasn't arrived yet is kept, but the chain can't end on it
+		if block.Height > lastBlock.Height && b.Get(block.PrevBlockHash) != nil {
 			err = b.Put([]byte("l"), block.Hash)
 			if err != nil {
 				log.Panic(err)
 			}
+			oldTip = lastBlock.Hash
 			bc.tip = block.Hash
 		}
 
@@ -130,10 +208,25 @@ func (bc *Blockchain) AddBlock(block *Block) {
 	if err != nil {
 		log.Panic(err)
 	}
+
+	bc.syncIndexes()
+
+	if oldTip != nil {
+		bc.publishTip(oldTip, block)
+	}
 }
 
-// FindTransaction finds a transaction by its ID
+// FindTransaction finds a transaction by its ID, in the transaction index unless the database has none
 func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
+	block, position, err := TxIndex{bc}.Find(ID)
+	if err != errTxIndexMissing {
+		if err != nil {
+			return Transaction{}, err
+		}
+
+		return *block.Transactions[position], nil
+	}
+
 	bci := bc.Iterator()
 
 	for {
@@ -153,38 +246,119 @@ func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
 	return Transaction{}, errors.New("Transaction is not found")
 }
 
-// FindUTXO finds all unspent transaction outputs and returns transactions with spent outputs removed
-func (bc *Blockchain) FindUTXO() map[string]TXOutputs {
-	UTXO := make(map[string]TXOutputs)
-	spentTXOs := make(map[string][]int)
+// FindTransactionBlock finds the block containing a transaction
+func (bc *Blockchain) FindTransactionBlock(ID []byte) (*Block, error) {
+	block, _, err := TxIndex{bc}.Find(ID)
+	if err != errTxIndexMissing {
+		return block, err
+	}
+
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		for _, tx := range block.Transactions {
+			if bytes.Compare(tx.ID, ID) == 0 {
+				return block, nil
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return nil, errors.New("Transaction is not found")
+}
+
+// FindData finds the transaction and block containing a data output with the given payload
+func (bc *Blockchain) FindData(data []byte) (*Transaction, *Block, error) {
 	bci := bc.Iterator()
 
 	for {
 		block := bci.Next()
 
 		for _, tx := range block.Transactions {
-			txID := hex.EncodeToString(tx.ID)
+			for _, out := range tx.Vout {
+				if out.IsUnspendable() && bytes.Compare(out.Data, data) == 0 {
+					return tx, block, nil
+				}
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return nil, nil, errors.New("Data is not found")
+}
+
+// IsTransactionFinal checks the absolute and relative lock times of a transaction
+// to be included in a block with the given height and time
+func (bc *Blockchain) IsTransactionFinal(tx *Transaction, height int, blockTime int64) bool {
+	if !tx.IsFinal(height, blockTime) {
+		return false
+	}
+
+	if tx.IsCoinbase() {
+		return true
+	}
+
+	for _, vin := range tx.Vin {
+		if vin.Sequence&sequenceLockTimeDisableFlag != 0 || vin.Sequence&sequenceLockTimeMask == 0 {
+			continue
+		}
+
+		prevBlock, err := bc.FindTransactionBlock(vin.Txid)
+		if err != nil {
+			return false
+		}
+
+		lock := int64(vin.Sequence & sequenceLockTimeMask)
+		if vin.Sequence&sequenceLockTimeTypeFlag != 0 {
+			if blockTime < prevBlock.Timestamp+lock<<sequenceLockTimeGranularity {
+				return false
+			}
+		} else if int64(height) < int64(prevBlock.Height)+lock {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FindUTXO finds all unspent transaction outputs, keyed by their chainstate key
+func (bc *Blockchain) FindUTXO() map[string]UTXOEntry {
+	return bc.findUTXOAt(bc.tip)
+}
+
+// findUTXOAt finds the unspent transaction outputs as they were right after the block blockHash
+func (bc *Blockchain) findUTXOAt(blockHash []byte) map[string]UTXOEntry {
+	UTXO := make(map[string]UTXOEntry)
+	spentTXOs := make(map[string]bool)
+	bci := &BlockchainIterator{blockHash, bc}
+
+	for {
+		block := bci.Next()
+
+		// Transactions go from the last one back, so outputs spent later in the same block are known
+		for i := len(block.Transactions) - 1; i >= 0; i-- {
+			tx := block.Transactions[i]
 
-		Outputs:
 			for outIdx, out := range tx.Vout {
-				// Was the output spent?
-				if spentTXOs[txID] != nil {
-					for _, spentOutIdx := range spentTXOs[txID] {
-						if spentOutIdx == outIdx {
-							continue Outputs
-						}
-					}
+				key := string(outpointKey(tx.ID, outIdx))
+				if out.IsUnspendable() || spentTXOs[key] {
+					continue
 				}
 
-				outs := UTXO[txID]
-				outs.Outputs = append(outs.Outputs, out)
-				UTXO[txID] = outs
+				UTXO[key] = UTXOEntry{out, block.Height, tx.IsCoinbase()}
 			}
 
 			if tx.IsCoinbase() == false {
 				for _, in := range tx.Vin {
-					inTxID := hex.EncodeToString(in.Txid)
-					spentTXOs[inTxID] = append(spentTXOs[inTxID], in.Vout)
+					spentTXOs[string(outpointKey(in.Txid, in.Vout))] = true
 				}
 			}
 		}
@@ -199,67 +373,65 @@ func (bc *Blockchain) FindUTXO() map[string]TXOutputs {
 
 // Iterator returns a BlockchainIterat
 func (bc *Blockchain) Iterator() *BlockchainIterator {
-	bci := &BlockchainIterator{bc.tip, bc.db}
+	bci := &BlockchainIterator{bc.tip, bc}
 
 	return bci
 }
 
+// ForwardIterator returns an iterator over the main chain from height up to the tip
+func (bc *Blockchain) ForwardIterator(height int) *BlockchainForwardIterator {
+	return &BlockchainForwardIterator{height, bc}
+}
+
 // GetBestHeight returns the height of the latest block
 func (bc *Blockchain) GetBestHeight() int {
-	var lastBlock Block
-
-	err := bc.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
-		lastHash := b.Get([]byte("l"))
-		blockData := b.Get(lastHash)
-		lastBlock = *DeserializeBlock(blockData)
-
-		return nil
-	})
-	if err != nil {
-		log.Panic(err)
-	}
-
-	return lastBlock.Height
+	return HeightIndex{bc}.BestHeight()
 }
 
 // GetBlock finds a block by its hash and returns it
 func (bc *Blockchain) GetBlock(blockHash []byte) (Block, error) {
-	var block Block
-
-	err := bc.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
-
-		blockData := b.Get(blockHash)
+	entry, err := bc.getBlockIndexEntry(blockHash)
+	if err != nil {
+		return Block{}, err
+	}
+	if entry.Status&blockHaveData == 0 {
+		return Block{}, fmt.Errorf("%w: %x", errBlockPruned, blockHash)
+	}
 
-		if blockData == nil {
-			return errors.New("Block is not found.")
-		}
+	blockData, err := bc.blocks.Read(entry.Pos)
+	if err != nil {
+		return Block{}, err
+	}
 
-		block = *DeserializeBlock(blockData)
+	return *DeserializeBlock(blockData), nil
+}
 
-		return nil
-	})
+// GetBlockByHeight returns the block of the main chain at height
+func (bc *Blockchain) GetBlockByHeight(height int) (Block, error) {
+	hash, err := HeightIndex{bc}.Hash(height)
 	if err != nil {
-		return block, err
+		return Block{}, err
 	}
 
-	return block, nil
+	return bc.GetBlock(hash)
 }
 
-// GetBlockHashes returns a list of hashes of all the blocks in the chain
+// GetBlockHashes returns a list of hashes of the blocks in the chain, leaving out the pruned ones
 func (bc *Blockchain) GetBlockHashes() [][]byte {
 	var blocks [][]byte
-	bci := bc.Iterator()
+	hash := bc.tip
 
-	for {
-		block := bci.Next()
-
-		blocks = append(blocks, block.Hash)
+	for len(hash) > 0 {
+		entry, err := bc.getBlockIndexEntry(hash)
+		if err != nil {
+			log.Panic(err)
+		}
 
-		if len(block.PrevBlockHash) == 0 {
-			break
+		if entry.Status&blockHaveData != 0 {
+			blocks = append(blocks, hash)
 		}
+
+		hash = entry.Header.PrevBlockHash
 	}
 
 	return blocks
@@ -277,14 +449,10 @@ func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
 		}
 	}
 
-	err := bc.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
-		lastHash = b.Get([]byte("l"))
-
-		blockData := b.Get(lastHash)
-		block := DeserializeBlock(blockData)
-
-		lastHeight = block.Height
+	err := bc.db.View(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(blockIndexBucket))
+		lastHash = append(lastHash, b.Get([]byte("l"))...)
+		lastHeight = deserializeBlockIndexEntry(b.Get(lastHash)).Header.Height
 
 		return nil
 	})
@@ -292,16 +460,21 @@ func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
 		log.Panic(err)
 	}
 
+	for _, tx := range transactions {
+		if bc.IsTransactionFinal(tx, lastHeight+1, time.Now().Unix()) != true {
+			log.Panic("ERROR: Transaction is not final")
+		}
+	}
+
 	newBlock := NewBlock(transactions, lastHash, lastHeight+1)
 
-	err = bc.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
-		err := b.Put(newBlock.Hash, newBlock.Serialize())
+	err = bc.db.Update(func(tx StoreTx) error {
+		err := bc.writeBlock(tx, newBlock)
 		if err != nil {
 			log.Panic(err)
 		}
 
-		err = b.Put([]byte("l"), newBlock.Hash)
+		err = tx.Bucket([]byte(blockIndexBucket)).Put([]byte("l"), newBlock.Hash)
 		if err != nil {
 			log.Panic(err)
 		}
@@ -314,30 +487,33 @@ func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
 		log.Panic(err)
 	}
 
+	bc.syncIndexes()
+	bc.publishTip(lastHash, newBlock)
+
 	return newBlock
 }
 
 // SignTransaction signs inputs of a Transaction
 func (bc *Blockchain) SignTransaction(tx *Transaction, privKey ecdsa.PrivateKey) {
-	prevTXs := make(map[string]Transaction)
-
-	for _, vin := range tx.Vin {
-		prevTX, err := bc.FindTransaction(vin.Txid)
-		if err != nil {
-			log.Panic(err)
-		}
-		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
-	}
+	prevTXs := bc.findPrevTransactions(tx)
 
 	tx.Sign(privKey, prevTXs)
 }
 
-// VerifyTransaction verifies transaction input signatures
+// VerifyTransaction verifies transaction input signatures and the outputs
 func (bc *Blockchain) VerifyTransaction(tx *Transaction) bool {
+	// A coinbase has no inputs to verify, only its outputs are checked
 	if tx.IsCoinbase() {
-		return true
+		return tx.Verify(nil)
 	}
 
+	prevTXs := bc.findPrevTransactions(tx)
+
+	return tx.Verify(prevTXs)
+}
+
+// findPrevTransactions returns the transactions referenced by the inputs of tx
+func (bc *Blockchain) findPrevTransactions(tx *Transaction) map[string]Transaction {
 	prevTXs := make(map[string]Transaction)
 
 	for _, vin := range tx.Vin {
@@ -348,13 +524,11 @@ func (bc *Blockchain) VerifyTransaction(tx *Transaction) bool {
 		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
 	}
 
-	return tx.Verify(prevTXs)
+	return prevTXs
 }
 
-func dbExists(dbFile string) bool {
-	if _, err := os.Stat(dbFile); os.IsNotExist(err) {
-		return false
-	}
-
-	return true
+// syncIndexes brings the transaction and height indexes up to the tip, after blocks are added
+func (bc *Blockchain) syncIndexes() {
+	TxIndex{bc}.Sync()
+	HeightIndex{bc}.Sync()
 }
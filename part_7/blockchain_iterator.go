@@ -1,34 +1,44 @@
 package main
 
 import (
+	"errors"
 	"log"
-
-	"github.com/boltdb/bolt"
 )
 
 // BlockchainIterator is used to iterate over blockchain blocks
 type BlockchainIterator struct {
 	currentHash []byte
-	db          *bolt.DB
+	bc          *Blockchain
 }
 
 // Next returns next block starting from the tip
 func (i *BlockchainIterator) Next() *Block {
-	var block *Block
-
-	err := i.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
-		encodedBlock := b.Get(i.currentHash)
-		block = DeserializeBlock(encodedBlock)
-
-		return nil
-	})
-
+	block, err := i.bc.GetBlock(i.currentHash)
 	if err != nil {
 		log.Panic(err)
 	}
 
 	i.currentHash = block.PrevBlockHash
 
-	return block
+	return &block
+}
+
+// BlockchainForwardIterator iterates over the blocks of the main chain from a height towards the tip
+type BlockchainForwardIterator struct {
+	height int
+	bc     *Blockchain
+}
+
+// Next returns the block at the next height, nil past the tip
+func (i *BlockchainForwardIterator) Next() *Block {
+	block, err := i.bc.GetBlockByHeight(i.height)
+	if errors.Is(err, errBlockPruned) {
+		log.Panic(err)
+	}
+	if err != nil {
+		return nil
+	}
+	i.height++
+
+	return &block
 }
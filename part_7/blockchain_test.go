@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestBlockchain creates a chain in memory and temporary block files, its genesis block pays address
+func newTestBlockchain(t *testing.T, address Address) *Blockchain {
+	blocks, err := openBlockFiles(t.TempDir())
+	assert.Nil(t, err)
+	bc := createBlockchainInStore(newMemoryStore(), blocks, address)
+	t.Cleanup(func() { bc.db.Close() })
+
+	return bc
+}
+
+func TestNewBlockchainResumesReindex(t *testing.T) {
+	dir, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(dir)
+
+	bc := CreateBlockchain(NewWallet().Address(), "test")
+	UTXOSet{bc}.startReindex()
+	bc.db.Close()
+
+	bc = NewBlockchain("test")
+	defer bc.db.Close()
+	assert.False(t, UTXOSet{bc}.IsReindexing())
+	assert.Equal(t, 1, UTXOSet{bc}.CountTransactions(), "The genesis coinbase is back in the UTXO set")
+}
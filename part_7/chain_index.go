@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"log"
+)
+
+// chainIndex is data derived from the blocks of the main chain, kept up to date by syncChainIndex
+type chainIndex interface {
+	ConnectBlock(block *Block)
+	DisconnectBlock(block *Block)
+	// syncedHash returns the last block connected to the index, nil when it's empty
+	syncedHash() []byte
+}
+
+// syncChainIndex brings index up to the tip of the chain, disconnecting blocks that left the main chain
+func syncChainIndex(bc *Blockchain, index chainIndex) {
+	synced := index.syncedHash()
+
+	// Blocks of the main chain the index hasn't seen yet, from the tip down. The synced block itself isn't read,
+	// it may be pruned
+	var connect []*Block
+	found := false
+	for hash := bc.tip; len(hash) > 0; {
+		if synced != nil && bytes.Compare(hash, synced) == 0 {
+			found = true
+			break
+		}
+
+		block, err := bc.GetBlock(hash)
+		if err != nil {
+			log.Panic(err)
+		}
+		connect = append(connect, &block)
+		hash = block.PrevBlockHash
+	}
+
+	if synced != nil && !found {
+		mainChain := make(map[string]bool)
+		for _, block := range connect {
+			mainChain[string(block.Hash)] = true
+		}
+
+		// The synced block is on a side branch now, go back to where it forks from the main chain
+		hash := synced
+		for len(hash) > 0 && !mainChain[string(hash)] {
+			block, err := bc.GetBlock(hash)
+			if err != nil {
+				log.Panic(err)
+			}
+			index.DisconnectBlock(&block)
+			hash = block.PrevBlockHash
+		}
+
+		for i, block := range connect {
+			if bytes.Compare(block.Hash, hash) == 0 {
+				connect = connect[:i]
+				break
+			}
+		}
+	}
+
+	for i := len(connect) - 1; i >= 0; i-- {
+		index.ConnectBlock(connect[i])
+	}
+}
+
+// indexTip returns the last block connected to the index kept in bucket, under the key "l"
+func indexTip(db Store, bucket string) []byte {
+	var hash []byte
+
+	err := db.View(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b != nil {
+			hash = append(hash, b.Get([]byte("l"))...)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return hash
+}
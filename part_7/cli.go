@@ -1,26 +1,103 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
 	"log"
+	"strings"
 
 	"os"
+
+	"golang.org/x/term"
 )
 
 // CLI responsible for processing command line arguments
 type CLI struct{}
 
 func (cli *CLI) printUsage() {
-	fmt.Println("Usage:")
+	fmt.Println("Usage (set NETWORK env. var. to mainnet, testnet or regtest, mainnet by default; STORE to bolt or leveldb, bolt by default):")
 	fmt.Println("  createblockchain -address ADDRESS - Create a blockchain and send genesis block reward to ADDRESS")
-	fmt.Println("  createwallet - Generates a new key-pair and saves it into the wallet file")
-	fmt.Println("  getbalance -address ADDRESS - Get balance of ADDRESS")
+	fmt.Println("  createhdwallet -words 12|24 -passphrase PASSPHRASE -type base58|bech32 - Create a wallet whose keys are derived from a new recovery phrase")
+	fmt.Println("  createmultisig -required M -pubkeys PUBKEY1,PUBKEY2,... - Create an M-of-N multisig address and save it into the wallet file")
+	fmt.Println("  createrawtx -from FROM -to TO -amount AMOUNT -change CHANGE -locktime LOCKTIME -sequence SEQUENCE -coinselect STRATEGY -out FILE - Create an unsigned transaction and save it with the outputs it spends to FILE. Change goes back to FROM unless CHANGE is set")
+	fmt.Println("  createwallet -type base58|bech32 - Generates a new key-pair and saves it into the wallet file")
+	fmt.Println("  dumpprivkey -address ADDRESS - Print the private key of a wallet ADDRESS in wallet import format")
+	fmt.Println("  dumputxoset -out FILE - Write the UTXO set with the headers of the chain and its hash to FILE")
+	fmt.Println("  encryptwallet - Encrypt the private keys in the wallet file with a passphrase read from the terminal or stdin")
+	fmt.Println("  finddata -data HEX | -file FILE - Find the transaction and block publishing HEX or the SHA-256 of FILE")
+	fmt.Println("  getaddresshistory -address ADDRESS - List the transactions of any ADDRESS from the address index")
+	fmt.Println("  getbalance -address ADDRESS - Get balance of ADDRESS, from the address index when it is built")
+	fmt.Println("  getpubkey -address ADDRESS - Print the public key of a wallet ADDRESS")
+	fmt.Println("  gettransaction -id TXID - Print how the wallet transaction TXID changed the wallet addresses, or any transaction TXID of the chain")
+	fmt.Println("  importaddress -address ADDRESS -rescan - Watch ADDRESS without being able to spend from it")
+	fmt.Println("  importprivkey -privkey KEY -rescan - Add a private key in wallet import format to the wallet file")
+	fmt.Println("  importpubkey -pubkey PUBKEY -rescan - Watch the address of a hex-encoded public key")
 	fmt.Println("  listaddresses - Lists all addresses from the wallet file")
-	fmt.Println("  printchain - Print all the blocks of the blockchain")
-	fmt.Println("  reindexutxo - Rebuilds the UTXO set")
-	fmt.Println("  send -from FROM -to TO -amount AMOUNT -mine - Send AMOUNT of coins from FROM address to TO. Mine on the same node, when -mine is set.")
-	fmt.Println("  startnode -miner ADDRESS - Start a node with ID specified in NODE_ID env. var. -miner enables mining")
+	fmt.Println("  listtransactions -address ADDRESS -count COUNT - List the last COUNT wallet transactions, of ADDRESS only when it is set")
+	fmt.Println("  loadutxoset -in FILE -hash HASH - Start a new node from the UTXO snapshot in FILE, whose hash must be HASH when set. The blocks below it are checked in the background.")
+	fmt.Println("  printchain -from HEIGHT -to HEIGHT - Print all the blocks of the blockchain, or the blocks from HEIGHT to HEIGHT in order")
+	fmt.Println("  publishdata -from FROM -data HEX | -file FILE -mine - Publish HEX or the SHA-256 of FILE in an unspendable output. Mine on the same node, when -mine is set.")
+	fmt.Println("  reindexaddresses -drop - Build the address index, or remove it when -drop is set")
+	fmt.Println("  reindextxs - Rebuilds the transaction index")
+	fmt.Println("  reindexutxo - Rebuilds the UTXO set, or resumes a reindex that was interrupted")
+	fmt.Println("  restorewallet -mnemonic PHRASE -passphrase PASSPHRASE - Restore an HD wallet and discover its used addresses")
+	fmt.Println("  rpc -method METHOD -params JSON - Call METHOD of the running node with a JSON array of params and print the result. getbalance, createwallet, send and sendrawtx also go through the node while it runs")
+	fmt.Println("  send -from FROM -to TO -amount AMOUNT -recipients FILE -locktime LOCKTIME -sequence SEQUENCE -coinselect STRATEGY -mine - Send AMOUNT of coins from FROM addresses to TO, or to every ADDRESS:AMOUNT of TO or the CSV/JSON FILE. Change goes to a new address. Mine on the same node, when -mine is set.")
+	fmt.Println("  sendrawtx -in FILE -mine -miner ADDRESS - Broadcast a fully signed transaction from FILE. Mine on the same node paying the reward to ADDRESS, when -mine is set.")
+	fmt.Println("  signrawtx -in FILE - Add signatures from the wallet file to the transaction in FILE, works offline")
+	fmt.Println("  startnode -miner ADDRESS -prune SIZE -dbcache SIZE -rest ADDRESS - Start a node with ID specified in NODE_ID env. var. -miner enables mining. -prune keeps the block files under SIZE MiB, deleting old blocks. -dbcache keeps up to SIZE MiB of UTXO set changes in memory. -rest serves the REST API and WebSocket feed on ADDRESS instead of with JSON-RPC on localhost")
+	fmt.Println("  verifychain -level N - Check the chain and report the first inconsistency. Level 0 checks proof of work, linkage and heights, 1 Merkle roots, 2 signatures and coinbase rules, 3 the UTXO set")
+	fmt.Println("  walletlock - Lock the wallet of the running node")
+	fmt.Println("  walletpassphrase -timeout SECONDS - Unlock the wallet of the running node for SECONDS with a passphrase read from the terminal or stdin")
+}
+
+// unlockWallets asks for the passphrase of an encrypted wallet, so the command can use its private keys
+func (cli *CLI) unlockWallets(wallets *Wallets) {
+	if !wallets.IsLocked() {
+		return
+	}
+
+	err := wallets.Unlock(readPassphrase("Wallet passphrase: "))
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// readPassphrase prompts for a passphrase and reads it from the terminal without echoing it,
+// or reads a line from stdin when it isn't a terminal. Passphrases aren't taken as flags, which show up in ps
+func readPassphrase(prompt string) string {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		passphrase, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && passphrase == "" {
+			log.Panic(err)
+		}
+
+		return strings.TrimRight(passphrase, "\r\n")
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return string(passphrase)
+}
+
+// rescanWallets rebuilds the wallet transaction history, so addresses added to the wallet show their past transactions
+func (cli *CLI) rescanWallets(wallets *Wallets, nodeID string) {
+	if !storeExists(nodeID) {
+		return
+	}
+
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+
+	history := WalletHistory{bc, wallets}
+	history.Rescan()
 }
 
 func (cli *CLI) validateArgs() {
@@ -40,24 +117,141 @@ func (cli *CLI) Run() {
 		os.Exit(1)
 	}
 
+	network, err := NetworkByName(os.Getenv("NETWORK"))
+	if err != nil {
+		log.Panic(err)
+	}
+	activeNetwork = network
+
+	backend, err := ParseBackend(os.Getenv("STORE"))
+	if err != nil {
+		log.Panic(err)
+	}
+	activeBackend = backend
+
+	findDataCmd := flag.NewFlagSet("finddata", flag.ExitOnError)
+	getAddressHistoryCmd := flag.NewFlagSet("getaddresshistory", flag.ExitOnError)
 	getBalanceCmd := flag.NewFlagSet("getbalance", flag.ExitOnError)
 	createBlockchainCmd := flag.NewFlagSet("createblockchain", flag.ExitOnError)
+	createHDWalletCmd := flag.NewFlagSet("createhdwallet", flag.ExitOnError)
+	createMultisigCmd := flag.NewFlagSet("createmultisig", flag.ExitOnError)
+	createRawTxCmd := flag.NewFlagSet("createrawtx", flag.ExitOnError)
 	createWalletCmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
+	dumpPrivKeyCmd := flag.NewFlagSet("dumpprivkey", flag.ExitOnError)
+	dumpUTXOSetCmd := flag.NewFlagSet("dumputxoset", flag.ExitOnError)
+	encryptWalletCmd := flag.NewFlagSet("encryptwallet", flag.ExitOnError)
+	getPubKeyCmd := flag.NewFlagSet("getpubkey", flag.ExitOnError)
+	getTransactionCmd := flag.NewFlagSet("gettransaction", flag.ExitOnError)
+	importAddressCmd := flag.NewFlagSet("importaddress", flag.ExitOnError)
+	importPrivKeyCmd := flag.NewFlagSet("importprivkey", flag.ExitOnError)
+	importPubKeyCmd := flag.NewFlagSet("importpubkey", flag.ExitOnError)
 	listAddressesCmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
+	listTransactionsCmd := flag.NewFlagSet("listtransactions", flag.ExitOnError)
+	loadUTXOSetCmd := flag.NewFlagSet("loadutxoset", flag.ExitOnError)
 	printChainCmd := flag.NewFlagSet("printchain", flag.ExitOnError)
+	publishDataCmd := flag.NewFlagSet("publishdata", flag.ExitOnError)
+	reindexAddressesCmd := flag.NewFlagSet("reindexaddresses", flag.ExitOnError)
+	reindexTxsCmd := flag.NewFlagSet("reindextxs", flag.ExitOnError)
 	reindexUTXOCmd := flag.NewFlagSet("reindexutxo", flag.ExitOnError)
+	restoreWalletCmd := flag.NewFlagSet("restorewallet", flag.ExitOnError)
+	rpcCmd := flag.NewFlagSet("rpc", flag.ExitOnError)
 	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
+	sendRawTxCmd := flag.NewFlagSet("sendrawtx", flag.ExitOnError)
+	signRawTxCmd := flag.NewFlagSet("signrawtx", flag.ExitOnError)
 	startNodeCmd := flag.NewFlagSet("startnode", flag.ExitOnError)
+	verifyChainCmd := flag.NewFlagSet("verifychain", flag.ExitOnError)
+	walletLockCmd := flag.NewFlagSet("walletlock", flag.ExitOnError)
+	walletPassphraseCmd := flag.NewFlagSet("walletpassphrase", flag.ExitOnError)
 
+	dumpPrivKeyAddress := dumpPrivKeyCmd.String("address", "", "The wallet address to export the private key of")
+	dumpUTXOSetOut := dumpUTXOSetCmd.String("out", "", "File to write the UTXO snapshot to")
+	findDataHex := findDataCmd.String("data", "", "Hex-encoded data to look for")
+	findDataFile := findDataCmd.String("file", "", "File whose SHA-256 to look for")
+	getAddressHistoryAddress := getAddressHistoryCmd.String("address", "", "The address to list transactions of")
 	getBalanceAddress := getBalanceCmd.String("address", "", "The address to get balance for")
 	createBlockchainAddress := createBlockchainCmd.String("address", "", "The address to send genesis block reward to")
-	sendFrom := sendCmd.String("from", "", "Source wallet address")
-	sendTo := sendCmd.String("to", "", "Destination wallet address")
+	createHDWalletWords := createHDWalletCmd.Int("words", 12, "Number of words in the recovery phrase")
+	createHDWalletPassphrase := createHDWalletCmd.String("passphrase", "", "Optional passphrase protecting the recovery phrase")
+	createHDWalletType := createHDWalletCmd.String("type", "base58", "Address type: base58 or bech32")
+	createMultisigRequired := createMultisigCmd.Int("required", 0, "Number of signatures required to spend")
+	createMultisigPubKeys := createMultisigCmd.String("pubkeys", "", "Comma separated list of hex-encoded public keys")
+	createRawTxFrom := createRawTxCmd.String("from", "", "Source wallet or multisig address")
+	createRawTxTo := createRawTxCmd.String("to", "", "Destination wallet address")
+	createRawTxAmount := createRawTxCmd.Int("amount", 0, "Amount to send")
+	createRawTxChange := createRawTxCmd.String("change", "", "Address the change goes to, the source address by default")
+	createRawTxLockTime := createRawTxCmd.Int64("locktime", 0, "Block height or unix time before which the transaction can't be mined")
+	createRawTxSequence := createRawTxCmd.Uint("sequence", 0, "Sequence of the inputs, sets a relative lock time")
+	createRawTxOut := createRawTxCmd.String("out", "", "File to save the unsigned transaction to")
+	createRawTxCoinSelect := createRawTxCmd.String("coinselect", defaultCoinSelection, "Coin selection strategy: largest, bnb, mininputs or privacy")
+	createWalletType := createWalletCmd.String("type", "base58", "Address type: base58 or bech32")
+	getPubKeyAddress := getPubKeyCmd.String("address", "", "The wallet address to print the public key of")
+	getTransactionID := getTransactionCmd.String("id", "", "ID of the wallet transaction")
+	importAddressAddress := importAddressCmd.String("address", "", "The address to watch")
+	importAddressRescan := importAddressCmd.Bool("rescan", true, "Look for past transactions of the address")
+	importPrivKeyKey := importPrivKeyCmd.String("privkey", "", "Private key in wallet import format")
+	importPrivKeyRescan := importPrivKeyCmd.Bool("rescan", true, "Look for past transactions of the key")
+	importPubKeyKey := importPubKeyCmd.String("pubkey", "", "Hex-encoded public key to watch")
+	importPubKeyRescan := importPubKeyCmd.Bool("rescan", true, "Look for past transactions of the key")
+	listTransactionsAddress := listTransactionsCmd.String("address", "", "Only list transactions of this wallet address")
+	listTransactionsCount := listTransactionsCmd.Int("count", 10, "Number of the most recent transactions to list, all when 0")
+	loadUTXOSetIn := loadUTXOSetCmd.String("in", "", "File with the UTXO snapshot")
+	loadUTXOSetHash := loadUTXOSetCmd.String("hash", "", "Expected hash of the UTXO snapshot, as printed by dumputxoset")
+	printChainFrom := printChainCmd.Int("from", -1, "Height of the first block to print")
+	printChainTo := printChainCmd.Int("to", -1, "Height of the last block to print, the tip when not set")
+	publishDataFrom := publishDataCmd.String("from", "", "Wallet address paying for the transaction")
+	publishDataHex := publishDataCmd.String("data", "", "Hex-encoded data to publish")
+	publishDataFile := publishDataCmd.String("file", "", "File whose SHA-256 to publish")
+	publishDataMine := publishDataCmd.Bool("mine", false, "Mine immediately on the same node")
+	reindexAddressesDrop := reindexAddressesCmd.Bool("drop", false, "Remove the address index instead of building it")
+	restoreWalletMnemonic := restoreWalletCmd.String("mnemonic", "", "Recovery phrase of the wallet")
+	restoreWalletPassphrase := restoreWalletCmd.String("passphrase", "", "Passphrase used when the wallet was created")
+	rpcMethod := rpcCmd.String("method", "", "Method to call: getblockchaininfo, getblock, getblockhash, getrawtransaction, sendrawtransaction, getmempoolinfo, getpeerinfo, getbalance, send or getnewaddress")
+	rpcParams := rpcCmd.String("params", "", "JSON array of the params of the method")
+	sendFrom := sendCmd.String("from", "", "Comma separated source wallet addresses, all wallet addresses when empty")
+	sendTo := sendCmd.String("to", "", "Destination wallet address, or comma separated ADDRESS:AMOUNT pairs")
 	sendAmount := sendCmd.Int("amount", 0, "Amount to send")
+	sendLockTime := sendCmd.Int64("locktime", 0, "Block height or unix time before which the transaction can't be mined")
+	sendSequence := sendCmd.Uint("sequence", 0, "Sequence of the inputs, sets a relative lock time")
 	sendMine := sendCmd.Bool("mine", false, "Mine immediately on the same node")
+	sendRecipientsFile := sendCmd.String("recipients", "", "CSV or JSON file with the addresses and amounts to pay")
+	sendCoinSelect := sendCmd.String("coinselect", defaultCoinSelection, "Coin selection strategy: largest, bnb, mininputs or privacy")
+	sendRawTxIn := sendRawTxCmd.String("in", "", "File with the signed transaction")
+	sendRawTxMine := sendRawTxCmd.Bool("mine", false, "Mine immediately on the same node")
+	sendRawTxMiner := sendRawTxCmd.String("miner", "", "The address to send the block reward to with -mine")
+	signRawTxIn := signRawTxCmd.String("in", "", "File with the transaction to sign")
 	startNodeMiner := startNodeCmd.String("miner", "", "Enable mining mode and send reward to ADDRESS")
+	startNodePrune := startNodeCmd.Int64("prune", 0, "Keep the block files under SIZE MiB by deleting old blocks, 0 keeps all of them")
+	startNodeDBCache := startNodeCmd.Int64("dbcache", defaultUTXOCacheSize, "Keep up to SIZE MiB of UTXO set changes in memory before writing them to the database")
+	startNodeREST := startNodeCmd.String("rest", "", "Serve the REST API and WebSocket feed on ADDRESS, such as 0.0.0.0:8080")
+	verifyChainLevel := verifyChainCmd.Int("level", maxVerifyLevel, "How thorough the check is, from 0 to 3")
+	walletPassphraseTimeout := walletPassphraseCmd.Int("timeout", 60, "Seconds to keep the wallet unlocked")
 
 	switch os.Args[1] {
+	case "dumpprivkey":
+		err := dumpPrivKeyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "dumputxoset":
+		err := dumpUTXOSetCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "encryptwallet":
+		err := encryptWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "finddata":
+		err := findDataCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getaddresshistory":
+		err := getAddressHistoryCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "getbalance":
 		err := getBalanceCmd.Parse(os.Args[2:])
 		if err != nil {
@@ -68,41 +262,177 @@ func (cli *CLI) Run() {
 		if err != nil {
 			log.Panic(err)
 		}
+	case "createhdwallet":
+		err := createHDWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createmultisig":
+		err := createMultisigCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createrawtx":
+		err := createRawTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "createwallet":
 		err := createWalletCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
+	case "getpubkey":
+		err := getPubKeyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "gettransaction":
+		err := getTransactionCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importaddress":
+		err := importAddressCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importprivkey":
+		err := importPrivKeyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importpubkey":
+		err := importPubKeyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "listaddresses":
 		err := listAddressesCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
+	case "listtransactions":
+		err := listTransactionsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "loadutxoset":
+		err := loadUTXOSetCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "printchain":
 		err := printChainCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
+	case "publishdata":
+		err := publishDataCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "reindexaddresses":
+		err := reindexAddressesCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "reindextxs":
+		err := reindexTxsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "reindexutxo":
 		err := reindexUTXOCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
+	case "restorewallet":
+		err := restoreWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "rpc":
+		err := rpcCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "send":
 		err := sendCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
+	case "sendrawtx":
+		err := sendRawTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signrawtx":
+		err := signRawTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "startnode":
 		err := startNodeCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
+	case "verifychain":
+		err := verifyChainCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "walletlock":
+		err := walletLockCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "walletpassphrase":
+		err := walletPassphraseCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	default:
 		cli.printUsage()
 		os.Exit(1)
 	}
 
+	if dumpPrivKeyCmd.Parsed() {
+		if *dumpPrivKeyAddress == "" {
+			dumpPrivKeyCmd.Usage()
+			os.Exit(1)
+		}
+		cli.dumpPrivKey(*dumpPrivKeyAddress, nodeID)
+	}
+
+	if dumpUTXOSetCmd.Parsed() {
+		if *dumpUTXOSetOut == "" {
+			dumpUTXOSetCmd.Usage()
+			os.Exit(1)
+		}
+		cli.dumpUTXOSet(*dumpUTXOSetOut, nodeID)
+	}
+
+	if encryptWalletCmd.Parsed() {
+		cli.encryptWallet(nodeID)
+	}
+
+	if findDataCmd.Parsed() {
+		if (*findDataHex == "") == (*findDataFile == "") {
+			findDataCmd.Usage()
+			os.Exit(1)
+		}
+		cli.findData(*findDataHex, *findDataFile, nodeID)
+	}
+
+	if getAddressHistoryCmd.Parsed() {
+		if *getAddressHistoryAddress == "" {
+			getAddressHistoryCmd.Usage()
+			os.Exit(1)
+		}
+		cli.getAddressHistory(*getAddressHistoryAddress, nodeID)
+	}
+
 	if getBalanceCmd.Parsed() {
 		if *getBalanceAddress == "" {
 			getBalanceCmd.Usage()
@@ -119,29 +449,153 @@ func (cli *CLI) Run() {
 		cli.createBlockchain(*createBlockchainAddress, nodeID)
 	}
 
+	if createHDWalletCmd.Parsed() {
+		if *createHDWalletWords != 12 && *createHDWalletWords != 24 {
+			createHDWalletCmd.Usage()
+			os.Exit(1)
+		}
+		cli.createHDWallet(*createHDWalletWords, *createHDWalletPassphrase, *createHDWalletType, nodeID)
+	}
+
+	if createMultisigCmd.Parsed() {
+		if *createMultisigRequired <= 0 || *createMultisigPubKeys == "" {
+			createMultisigCmd.Usage()
+			os.Exit(1)
+		}
+		cli.createMultisig(*createMultisigRequired, *createMultisigPubKeys, nodeID)
+	}
+
+	if createRawTxCmd.Parsed() {
+		if *createRawTxFrom == "" || *createRawTxTo == "" || *createRawTxAmount <= 0 || *createRawTxOut == "" {
+			createRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		cli.createRawTx(*createRawTxFrom, *createRawTxTo, *createRawTxChange, *createRawTxAmount, *createRawTxLockTime, *createRawTxSequence, *createRawTxCoinSelect, *createRawTxOut, nodeID)
+	}
+
 	if createWalletCmd.Parsed() {
-		cli.createWallet(nodeID)
+		cli.createWallet(*createWalletType, nodeID)
+	}
+
+	if getPubKeyCmd.Parsed() {
+		if *getPubKeyAddress == "" {
+			getPubKeyCmd.Usage()
+			os.Exit(1)
+		}
+		cli.getPubKey(*getPubKeyAddress, nodeID)
+	}
+
+	if getTransactionCmd.Parsed() {
+		if *getTransactionID == "" {
+			getTransactionCmd.Usage()
+			os.Exit(1)
+		}
+		cli.getTransaction(*getTransactionID, nodeID)
+	}
+
+	if importAddressCmd.Parsed() {
+		if *importAddressAddress == "" {
+			importAddressCmd.Usage()
+			os.Exit(1)
+		}
+		cli.importAddress(*importAddressAddress, *importAddressRescan, nodeID)
+	}
+
+	if importPrivKeyCmd.Parsed() {
+		if *importPrivKeyKey == "" {
+			importPrivKeyCmd.Usage()
+			os.Exit(1)
+		}
+		cli.importPrivKey(*importPrivKeyKey, *importPrivKeyRescan, nodeID)
+	}
+
+	if importPubKeyCmd.Parsed() {
+		if *importPubKeyKey == "" {
+			importPubKeyCmd.Usage()
+			os.Exit(1)
+		}
+		cli.importPubKey(*importPubKeyKey, *importPubKeyRescan, nodeID)
 	}
 
 	if listAddressesCmd.Parsed() {
 		cli.listAddresses(nodeID)
 	}
 
+	if listTransactionsCmd.Parsed() {
+		cli.listTransactions(*listTransactionsAddress, *listTransactionsCount, nodeID)
+	}
+
+	if loadUTXOSetCmd.Parsed() {
+		if *loadUTXOSetIn == "" {
+			loadUTXOSetCmd.Usage()
+			os.Exit(1)
+		}
+		cli.loadUTXOSet(*loadUTXOSetIn, *loadUTXOSetHash, nodeID)
+	}
+
 	if printChainCmd.Parsed() {
-		cli.printChain(nodeID)
+		cli.printChain(*printChainFrom, *printChainTo, nodeID)
+	}
+
+	if publishDataCmd.Parsed() {
+		if *publishDataFrom == "" || (*publishDataHex == "") == (*publishDataFile == "") {
+			publishDataCmd.Usage()
+			os.Exit(1)
+		}
+		cli.publishData(*publishDataFrom, *publishDataHex, *publishDataFile, nodeID, *publishDataMine)
+	}
+
+	if reindexAddressesCmd.Parsed() {
+		cli.reindexAddresses(*reindexAddressesDrop, nodeID)
+	}
+
+	if reindexTxsCmd.Parsed() {
+		cli.reindexTxs(nodeID)
 	}
 
 	if reindexUTXOCmd.Parsed() {
 		cli.reindexUTXO(nodeID)
 	}
 
+	if restoreWalletCmd.Parsed() {
+		if *restoreWalletMnemonic == "" {
+			restoreWalletCmd.Usage()
+			os.Exit(1)
+		}
+		cli.restoreWallet(*restoreWalletMnemonic, *restoreWalletPassphrase, nodeID)
+	}
+
+	if rpcCmd.Parsed() {
+		if *rpcMethod == "" {
+			rpcCmd.Usage()
+			os.Exit(1)
+		}
+		cli.rpc(*rpcMethod, *rpcParams, nodeID)
+	}
+
 	if sendCmd.Parsed() {
-		if *sendFrom == "" || *sendTo == "" || *sendAmount <= 0 {
+		if (*sendTo == "") == (*sendRecipientsFile == "") {
 			sendCmd.Usage()
 			os.Exit(1)
 		}
 
-		cli.send(*sendFrom, *sendTo, *sendAmount, nodeID, *sendMine)
+		cli.send(*sendFrom, *sendTo, *sendAmount, *sendRecipientsFile, *sendLockTime, *sendSequence, *sendCoinSelect, nodeID, *sendMine)
+	}
+
+	if sendRawTxCmd.Parsed() {
+		if *sendRawTxIn == "" || (*sendRawTxMine && *sendRawTxMiner == "") {
+			sendRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		cli.sendRawTx(*sendRawTxIn, *sendRawTxMiner, nodeID, *sendRawTxMine)
+	}
+
+	if signRawTxCmd.Parsed() {
+		if *signRawTxIn == "" {
+			signRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		cli.signRawTx(*signRawTxIn, nodeID)
 	}
 
 	if startNodeCmd.Parsed() {
@@ -150,6 +604,26 @@ func (cli *CLI) Run() {
 			startNodeCmd.Usage()
 			os.Exit(1)
 		}
-		cli.startNode(nodeID, *startNodeMiner)
+		cli.startNode(nodeID, *startNodeMiner, *startNodeREST, *startNodePrune, *startNodeDBCache)
+	}
+
+	if verifyChainCmd.Parsed() {
+		if *verifyChainLevel < 0 || *verifyChainLevel > maxVerifyLevel {
+			verifyChainCmd.Usage()
+			os.Exit(1)
+		}
+		cli.verifyChain(*verifyChainLevel, nodeID)
+	}
+
+	if walletLockCmd.Parsed() {
+		cli.walletLock(nodeID)
+	}
+
+	if walletPassphraseCmd.Parsed() {
+		if *walletPassphraseTimeout <= 0 {
+			walletPassphraseCmd.Usage()
+			os.Exit(1)
+		}
+		cli.walletPassphrase(*walletPassphraseTimeout, nodeID)
 	}
 }
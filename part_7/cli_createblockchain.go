@@ -6,10 +6,11 @@ import (
 )
 
 func (cli *CLI) createBlockchain(address, nodeID string) {
-	if !ValidateAddress(address) {
-		log.Panic("ERROR: Address is not valid")
+	to, err := DecodeAddress(address)
+	if err != nil {
+		log.Panic(err)
 	}
-	bc := CreateBlockchain(address, nodeID)
+	bc := CreateBlockchain(to, nodeID)
 	defer bc.db.Close()
 
 	UTXOSet := UTXOSet{bc}
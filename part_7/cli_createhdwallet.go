@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+func (cli *CLI) createHDWallet(words int, passphrase, addressType, nodeID string) {
+	encoding, err := ParseAddressEncoding(addressType)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	entropy, err := bip39.NewEntropy(words / 3 * 32)
+	if err != nil {
+		log.Panic(err)
+	}
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	wallets, _ := NewWallets(nodeID)
+	cli.unlockWallets(wallets)
+	err = wallets.SetSeed(bip39.NewSeed(mnemonic, passphrase))
+	if err != nil {
+		log.Panic(err)
+	}
+	address := wallets.CreateWallet(encoding)
+	wallets.SaveToFile(nodeID)
+
+	fmt.Println("Write down your recovery phrase, it is the only way to restore the wallet:")
+	fmt.Printf("\n  %s\n\n", mnemonic)
+	fmt.Printf("Your new address: %s\n", address)
+}
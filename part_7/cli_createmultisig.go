@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+)
+
+func (cli *CLI) createMultisig(required int, pubKeys, nodeID string) {
+	var keys [][]byte
+
+	for _, pubKey := range strings.Split(pubKeys, ",") {
+		key, err := hex.DecodeString(strings.TrimSpace(pubKey))
+		if err != nil {
+			log.Panic(err)
+		}
+		keys = append(keys, key)
+	}
+
+	script, err := NewMultisigScript(required, keys)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	wallets, _ := NewWallets(nodeID)
+	address := wallets.AddMultisig(script)
+	wallets.SaveToFile(nodeID)
+
+	fmt.Printf("Your new %d-of-%d multisig address: %s\n", required, len(keys), address)
+	fmt.Printf("Redeem script: %x\n", script.Serialize())
+}
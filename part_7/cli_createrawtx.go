@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+func (cli *CLI) createRawTx(from, to, change string, amount int, lockTime int64, sequence uint, coinSelection, file, nodeID string) {
+	fromAddress, err := DecodeAddress(from)
+	if err != nil {
+		log.Panic(err)
+	}
+	toAddress, err := DecodeAddress(to)
+	if err != nil {
+		log.Panic(err)
+	}
+	selector, err := GetCoinSelector(coinSelection)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Change goes back to the address it's spent from, so a multisig's change stays under shared custody,
+	// unless -change names another address. No key is made here, a watch-only coordinator may have no usable wallet
+	var changeAddress Address
+	if change != "" {
+		changeAddress, err = DecodeAddress(change)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	redeemScripts := make(map[string][]byte)
+	wallets, err := NewWallets(nodeID)
+	if err == nil {
+		if script, ok := wallets.GetMultisig(fromAddress); ok {
+			redeemScripts[hex.EncodeToString(script.Hash())] = script.Serialize()
+		}
+	}
+
+	bc := NewBlockchain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
+
+	tx := NewUnsignedTransaction([]Address{fromAddress}, []Recipient{{toAddress, amount}}, changeAddress, selector, redeemScripts, &UTXOSet)
+	tx.SetLockTime(lockTime, uint32(sequence))
+	ptx := NewPartialTransaction(tx, bc)
+	ptx.SaveToFile(file)
+
+	fmt.Printf("Unsigned transaction %x saved to %s\n", tx.ID, file)
+}
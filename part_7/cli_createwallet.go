@@ -1,10 +1,26 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"log"
+)
+
+func (cli *CLI) createWallet(addressType, nodeID string) {
+	encoding, err := ParseAddressEncoding(addressType)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// The wallet of a running node is in its memory, the node adds the address
+	var address string
+	if callNode(nodeID, "getnewaddress", &address, addressType) {
+		fmt.Printf("Your new address: %s\n", address)
+		return
+	}
 
-func (cli *CLI) createWallet(nodeID string) {
 	wallets, _ := NewWallets(nodeID)
-	address := wallets.CreateWallet()
+	cli.unlockWallets(wallets)
+	address = wallets.CreateWallet(encoding)
 	wallets.SaveToFile(nodeID)
 
 	fmt.Printf("Your new address: %s\n", address)
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+func (cli *CLI) dumpPrivKey(address, nodeID string) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	cli.unlockWallets(wallets)
+
+	wif, err := wallets.DumpPrivateKey(address)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(wif)
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+func (cli *CLI) dumpUTXOSet(out, nodeID string) {
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+
+	snapshot, err := UTXOSet{bc}.Snapshot()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = snapshot.Write(out)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Done! The UTXO set of %d transactions at height %d, block %x, is written to %s\n", len(snapshot.UTXO), snapshot.Height, snapshot.Tip, out)
+	fmt.Printf("Hash: %x\n", snapshot.Hash)
+}
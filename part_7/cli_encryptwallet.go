@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"golang.org/x/term"
+)
+
+func (cli *CLI) encryptWallet(nodeID string) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	passphrase := readPassphrase("New wallet passphrase: ")
+	if passphrase == "" {
+		log.Panic("ERROR: Passphrase is empty")
+	}
+	if term.IsTerminal(int(os.Stdin.Fd())) && readPassphrase("Repeat the passphrase: ") != passphrase {
+		log.Panic("ERROR: Passphrases don't match")
+	}
+
+	err = wallets.EncryptWallet(passphrase)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(nodeID)
+
+	fmt.Println("Wallet encrypted. Keep the passphrase safe, the private keys can't be used without it.")
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+func (cli *CLI) findData(dataHex, file, nodeID string) {
+	data := dataPayload(dataHex, file)
+
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+
+	tx, block, err := bc.FindData(data)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Data %x found\n", data)
+	fmt.Printf("Transaction: %x\n", tx.ID)
+	fmt.Printf("Block: %x\n", block.Hash)
+	fmt.Printf("Height: %d\n", block.Height)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+func (cli *CLI) getAddressHistory(address, nodeID string) {
+	decoded, err := DecodeAddress(address)
+	if err != nil {
+		log.Panic(err)
+	}
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+
+	index := AddressIndex{bc}
+	index.Sync()
+
+	history, err := index.History(decoded.Hash)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	balance := 0
+	for _, atx := range history {
+		balance += atx.Amount
+		fmt.Printf("%6d  %x  %+d\n", atx.Height, atx.TxID, atx.Amount)
+	}
+
+	fmt.Printf("%d transactions, balance of '%s': %d\n", len(history), address, balance)
+}
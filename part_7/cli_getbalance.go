@@ -6,21 +6,48 @@ import (
 )
 
 func (cli *CLI) getBalance(address, nodeID string) {
-	if !ValidateAddress(address) {
-		log.Panic("ERROR: Address is not valid")
+	decoded, err := DecodeAddress(address)
+	if err != nil {
+		log.Panic(err)
 	}
+
+	// A running node holds the database, it answers instead
+	var balance int
+	if callNode(nodeID, "getbalance", &balance, address) {
+		fmt.Printf("Balance of '%s': %d\n", address, balance)
+		return
+	}
+
 	bc := NewBlockchain(nodeID)
-	UTXOSet := UTXOSet{bc}
 	defer bc.db.Close()
 
+	index := AddressIndex{bc}
+	if index.IsEnabled() {
+		index.Sync()
+	}
+
+	fmt.Printf("Balance of '%s': %d\n", address, addressBalance(bc, decoded))
+}
+
+// addressBalance sums the unspent outputs locked to address
+func addressBalance(bc *Blockchain, address Address) int {
+	// The address index answers without scanning the whole UTXO set
+	index := AddressIndex{bc}
+	if index.IsEnabled() {
+		balance, err := index.Balance(address.Hash)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		return balance
+	}
+
 	balance := 0
-	pubKeyHash := Base58Decode([]byte(address))
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
-	UTXOs := UTXOSet.FindUTXO(pubKeyHash)
+	UTXOs := UTXOSet{bc}.FindUTXO(address.Hash)
 
 	for _, out := range UTXOs {
 		balance += out.Value
 	}
 
-	fmt.Printf("Balance of '%s': %d\n", address, balance)
+	return balance
 }
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+func (cli *CLI) getPubKey(address, nodeID string) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if pubKey := wallets.Watched[address]; pubKey != nil {
+		fmt.Printf("%x\n", pubKey)
+		return
+	}
+
+	wallet, ok := wallets.Wallets[address]
+	if !ok {
+		log.Panic("ERROR: Address is not in the wallet")
+	}
+
+	fmt.Printf("%x\n", wallet.PublicKey)
+}
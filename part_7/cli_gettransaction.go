@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+func (cli *CLI) getTransaction(id, nodeID string) {
+	txID, err := hex.DecodeString(id)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	history := WalletHistory{bc, wallets}
+	history.Sync()
+
+	wtx, ok := history.FindTransaction(txID)
+	if !ok {
+		printChainTransaction(bc, txID)
+		return
+	}
+
+	fmt.Printf("Transaction:   %x\n", wtx.Tx.ID)
+	fmt.Printf("Amount:        %+d\n", wtx.Amount())
+	fmt.Printf("Fee:           %d\n", wtx.Fee)
+	fmt.Printf("Counterparty:  %s\n", counterparties(wtx))
+	fmt.Printf("Confirmations: %d\n", bc.GetBestHeight()-wtx.Height+1)
+	fmt.Printf("Block:         %x\n", wtx.BlockHash)
+	fmt.Printf("Height:        %d\n", wtx.Height)
+	fmt.Printf("Time:          %s\n", time.Unix(wtx.Timestamp, 0).Format(time.RFC3339))
+
+	var addresses []string
+	for address := range wtx.Deltas {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	fmt.Println("Addresses:")
+	for _, address := range addresses {
+		fmt.Printf("  %s %+d\n", address, wtx.Deltas[address])
+	}
+
+	fmt.Println(wtx.Tx)
+}
+
+// printChainTransaction prints a transaction of the main chain that doesn't touch the wallet
+func printChainTransaction(bc *Blockchain, txID []byte) {
+	block, position, err := TxIndex{bc}.Find(txID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Transaction:   %x\n", txID)
+	fmt.Printf("Confirmations: %d\n", bc.GetBestHeight()-block.Height+1)
+	fmt.Printf("Block:         %x\n", block.Hash)
+	fmt.Printf("Height:        %d\n", block.Height)
+	fmt.Printf("Position:      %d\n", position)
+	fmt.Printf("Time:          %s\n", time.Unix(block.Timestamp, 0).Format(time.RFC3339))
+
+	fmt.Println(block.Transactions[position])
+}
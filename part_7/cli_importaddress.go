@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+func (cli *CLI) importAddress(address string, rescan bool, nodeID string) {
+	wallets, _ := NewWallets(nodeID)
+	err := wallets.ImportAddress(address)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(nodeID)
+
+	if rescan {
+		cli.rescanWallets(wallets, nodeID)
+	}
+
+	fmt.Printf("Watching %s\n", address)
+}
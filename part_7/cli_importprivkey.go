@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+func (cli *CLI) importPrivKey(wif string, rescan bool, nodeID string) {
+	wallets, _ := NewWallets(nodeID)
+	cli.unlockWallets(wallets)
+
+	address, err := wallets.ImportPrivateKey(wif)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(nodeID)
+
+	if rescan {
+		cli.rescanWallets(wallets, nodeID)
+	}
+
+	fmt.Printf("Imported %s\n", address)
+}
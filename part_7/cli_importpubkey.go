@@ -0,0 +1,27 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+func (cli *CLI) importPubKey(pubKeyHex string, rescan bool, nodeID string) {
+	pubKey, err := hex.DecodeString(pubKeyHex)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	wallets, _ := NewWallets(nodeID)
+	address, err := wallets.ImportPubKey(pubKey)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(nodeID)
+
+	if rescan {
+		cli.rescanWallets(wallets, nodeID)
+	}
+
+	fmt.Printf("Watching %s\n", address)
+}
@@ -15,4 +15,12 @@ func (cli *CLI) listAddresses(nodeID string) {
 	for _, address := range addresses {
 		fmt.Println(address)
 	}
+
+	for address, script := range wallets.Scripts {
+		fmt.Printf("%s (multisig %d-of-%d)\n", address, script.Required, len(script.PubKeys))
+	}
+
+	for address := range wallets.Watched {
+		fmt.Printf("%s (watch-only)\n", address)
+	}
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+func (cli *CLI) listTransactions(address string, count int, nodeID string) {
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if address != "" {
+		decoded, err := DecodeAddress(address)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		var ok bool
+		address, ok = wallets.FindAddress(decoded)
+		if !ok {
+			log.Panic("ERROR: Address is not in the wallet")
+		}
+	}
+
+	history := WalletHistory{bc, wallets}
+	history.Sync()
+
+	wtxs := history.Transactions(address)
+	if count > 0 && len(wtxs) > count {
+		wtxs = wtxs[len(wtxs)-count:]
+	}
+	bestHeight := bc.GetBestHeight()
+
+	for _, wtx := range wtxs {
+		amount := wtx.Amount()
+		if address != "" {
+			amount = wtx.Deltas[address]
+		}
+
+		fmt.Printf("%x\n", wtx.Tx.ID)
+		fmt.Printf("  Amount:        %+d\n", amount)
+		fmt.Printf("  Fee:           %d\n", wtx.Fee)
+		fmt.Printf("  Counterparty:  %s\n", counterparties(wtx))
+		fmt.Printf("  Confirmations: %d\n", bestHeight-wtx.Height+1)
+		fmt.Printf("  Block:         %x\n", wtx.BlockHash)
+	}
+}
+
+// counterparties describes who the other side of a wallet transaction is
+func counterparties(wtx WalletTx) string {
+	if wtx.Tx.IsCoinbase() {
+		return "coinbase"
+	}
+	if len(wtx.Counterparties) == 0 {
+		return "self"
+	}
+
+	return strings.Join(wtx.Counterparties, ", ")
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+)
+
+func (cli *CLI) loadUTXOSet(in, hash, nodeID string) {
+	if storeExists(nodeID) {
+		fmt.Println("Blockchain already exists.")
+		os.Exit(1)
+	}
+
+	snapshot, err := readUTXOSnapshot(in)
+	if err != nil {
+		log.Panic(err)
+	}
+	if hash != "" && hash != hex.EncodeToString(snapshot.Hash) {
+		log.Panic("ERROR: UTXO snapshot hash doesn't match")
+	}
+
+	db, err := openStore(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer db.Close()
+
+	err = loadUTXOSnapshot(db, snapshot)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Done! The node starts at height %d, block %x, from the UTXO snapshot %x\n", snapshot.Height, snapshot.Tip, snapshot.Hash)
+	fmt.Println("Run startnode to sync the blocks after the snapshot and check the ones below it.")
+}
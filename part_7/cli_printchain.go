@@ -5,27 +5,48 @@ import (
 	"strconv"
 )
 
-func (cli *CLI) printChain(nodeID string) {
+// printChain prints the chain from the tip down, or the blocks from height from up to height to when either is set
+func (cli *CLI) printChain(from, to int, nodeID string) {
 	bc := NewBlockchain(nodeID)
 	defer bc.db.Close()
 
+	if from >= 0 || to >= 0 {
+		if from < 0 {
+			from = 0
+		}
+		if to < 0 {
+			to = bc.GetBestHeight()
+		}
+
+		bci := bc.ForwardIterator(from)
+		for block := bci.Next(); block != nil && block.Height <= to; block = bci.Next() {
+			printBlock(block)
+		}
+
+		return
+	}
+
 	bci := bc.Iterator()
 
 	for {
 		block := bci.Next()
 
-		fmt.Printf("============ Block %x ============\n", block.Hash)
-		fmt.Printf("Height: %d\n", block.Height)
-		fmt.Printf("Prev. block: %x\n", block.PrevBlockHash)
-		pow := NewProofOfWork(block)
-		fmt.Printf("PoW: %s\n\n", strconv.FormatBool(pow.Validate()))
-		for _, tx := range block.Transactions {
-			fmt.Println(tx)
-		}
-		fmt.Printf("\n\n")
+		printBlock(block)
 
 		if len(block.PrevBlockHash) == 0 {
 			break
 		}
 	}
 }
+
+func printBlock(block *Block) {
+	fmt.Printf("============ Block %x ============\n", block.Hash)
+	fmt.Printf("Height: %d\n", block.Height)
+	fmt.Printf("Prev. block: %x\n", block.PrevBlockHash)
+	pow := NewProofOfWork(block)
+	fmt.Printf("PoW: %s\n\n", strconv.FormatBool(pow.Validate()))
+	for _, tx := range block.Transactions {
+		fmt.Println(tx)
+	}
+	fmt.Printf("\n\n")
+}
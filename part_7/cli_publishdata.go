@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+)
+
+func (cli *CLI) publishData(from, dataHex, file, nodeID string, mineNow bool) {
+	address, err := DecodeAddress(from)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	data := dataPayload(dataHex, file)
+	if len(data) > maxDataCarrierSize {
+		log.Panicf("ERROR: Data can't be larger than %d bytes", maxDataCarrierSize)
+	}
+
+	bc := NewBlockchain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
+
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	cli.unlockWallets(wallets)
+	wallet := wallets.GetWallet(from)
+
+	tx := NewDataTransaction(&wallet, data, &UTXOSet)
+
+	if mineNow {
+		cbTx := NewCoinbaseTX(address, "")
+		txs := []*Transaction{cbTx, tx}
+
+		newBlock := bc.MineBlock(txs)
+		UTXOSet.Update(newBlock)
+	} else {
+		sendTx(knownNodes[0], tx)
+	}
+
+	fmt.Printf("Published %x in transaction %x\n", data, tx.ID)
+}
+
+// dataPayload returns the hex-decoded data or, when a file is given, the SHA-256 of the file
+func dataPayload(dataHex, file string) []byte {
+	if file != "" {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Panic(err)
+		}
+		hash := sha256.Sum256(content)
+
+		return hash[:]
+	}
+
+	data, err := hex.DecodeString(dataHex)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return data
+}
@@ -0,0 +1,19 @@
+package main
+
+import "fmt"
+
+func (cli *CLI) reindexAddresses(drop bool, nodeID string) {
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+
+	index := AddressIndex{bc}
+	if drop {
+		index.Drop()
+		fmt.Println("Done! The address index is removed.")
+		return
+	}
+
+	bc.requireAllBlocks()
+	index.Reindex()
+	fmt.Println("Done! The address index is built and will follow the chain.")
+}
@@ -0,0 +1,13 @@
+package main
+
+import "fmt"
+
+func (cli *CLI) reindexTxs(nodeID string) {
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+	bc.requireAllBlocks()
+
+	TxIndex{bc}.Reindex()
+
+	fmt.Println("Done! The transaction index is rebuilt.")
+}
@@ -3,10 +3,21 @@ package main
 import "fmt"
 
 func (cli *CLI) reindexUTXO(nodeID string) {
-	bc := NewBlockchain(nodeID)
+	// The reindex resumes by itself, NewBlockchain would finish it and then it would start over
+	bc := openBlockchain(nodeID)
+	bc.requireAllBlocks()
 	UTXOSet := UTXOSet{bc}
-	UTXOSet.Reindex()
+
+	if UTXOSet.IsReindexing() {
+		fmt.Println("Resuming the interrupted reindex")
+	}
+	UTXOSet.ReindexWithProgress(printReindexProgress)
+	fmt.Println()
 
 	count := UTXOSet.CountTransactions()
 	fmt.Printf("Done! There are %d transactions in the UTXO set.\n", count)
 }
+
+func printReindexProgress(height, bestHeight int) {
+	fmt.Printf("\rReindexed blocks up to height %d of %d", height, bestHeight)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+func (cli *CLI) restoreWallet(mnemonic, passphrase, nodeID string) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	wallets, _ := NewWallets(nodeID)
+	cli.unlockWallets(wallets)
+	err = wallets.SetSeed(seed)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Without a blockchain there is nothing to discover, only the first address is created
+	if storeExists(nodeID) {
+		bc := NewBlockchain(nodeID)
+		UTXOSet := UTXOSet{bc}
+
+		wallets.Discover(func(pubKeyHash []byte) bool {
+			return len(UTXOSet.FindUTXO(pubKeyHash)) > 0
+		})
+		bc.db.Close()
+	}
+
+	if wallets.NextIndex[receiveChain] == 0 {
+		wallets.CreateWallet(base58Encoding)
+	}
+	wallets.SaveToFile(nodeID)
+	cli.rescanWallets(wallets, nodeID)
+
+	fmt.Printf("Restored %d receive and %d change addresses\n", wallets.NextIndex[receiveChain], wallets.NextIndex[changeChain])
+	for _, address := range wallets.GetAddresses() {
+		fmt.Println(address)
+	}
+}
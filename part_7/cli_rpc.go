@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+)
+
+// rpc calls method on the running node with params, a JSON array, and prints the result
+func (cli *CLI) rpc(method, params, nodeID string) {
+	var decoded []interface{}
+	if params != "" {
+		err := json.Unmarshal([]byte(params), &decoded)
+		if err != nil {
+			log.Panicf("ERROR: Params must be a JSON array: %s", err)
+		}
+	}
+
+	var result json.RawMessage
+	if !callNode(nodeID, method, &result, decoded...) {
+		fmt.Printf("Node %s is not running\n", nodeID)
+		os.Exit(1)
+	}
+
+	var out bytes.Buffer
+	err := json.Indent(&out, result, "", "  ")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(out.String())
+}
@@ -3,30 +3,53 @@ package main
 import (
 	"fmt"
 	"log"
+	"strings"
+	"time"
 )
 
-func (cli *CLI) send(from, to string, amount int, nodeID string, mineNow bool) {
-	if !ValidateAddress(from) {
-		log.Panic("ERROR: Sender address is not valid")
+// send pays the recipients from the from addresses, a comma separated list.
+// All addresses of the wallet are spent from when from is empty
+func (cli *CLI) send(from, to string, amount int, recipientsFile string, lockTime int64, sequence uint, coinSelection, nodeID string, mineNow bool) {
+	recipients := sendRecipients(to, amount, recipientsFile)
+	selector, err := GetCoinSelector(coinSelection)
+	if err != nil {
+		log.Panic(err)
 	}
-	if !ValidateAddress(to) {
-		log.Panic("ERROR: Recipient address is not valid")
+
+	// A running node holds the database, it makes and sends the transaction with its own wallet
+	var txID string
+	if !mineNow && callNode(nodeID, "send", &txID, recipients, from, coinSelection, lockTime, uint32(sequence)) {
+		fmt.Printf("Success! Transaction %s\n", txID)
+		return
 	}
 
 	bc := NewBlockchain(nodeID)
 	UTXOSet := UTXOSet{bc}
 	defer bc.db.Close()
 
+	if !lockTimeReached(lockTime, uint32(sequence), bc.GetBestHeight()+1, time.Now().Unix()) {
+		log.Panic("ERROR: Transaction is locked, use createrawtx and send it with sendrawtx once it is final")
+	}
+
 	wallets, err := NewWallets(nodeID)
 	if err != nil {
 		log.Panic(err)
 	}
-	wallet := wallets.GetWallet(from)
+	cli.unlockWallets(wallets)
+	senders := walletSenders(wallets, from)
+
+	// Change looks like the address it's spent from
+	change := wallets.Wallets[wallets.CreateChangeWallet(senders[0].Encoding)].Address()
 
-	tx := NewUTXOTransaction(&wallet, to, amount, &UTXOSet)
+	tx := NewUTXOTransaction(senders, recipients, change, selector, lockTime, uint32(sequence), &UTXOSet)
+
+	// Keep the change key before the transaction leaves this node
+	if len(tx.Vout) > len(recipients) {
+		wallets.SaveToFile(nodeID)
+	}
 
 	if mineNow {
-		cbTx := NewCoinbaseTX(from, "")
+		cbTx := NewCoinbaseTX(senders[0].Address(), "")
 		txs := []*Transaction{cbTx, tx}
 
 		newBlock := bc.MineBlock(txs)
@@ -37,3 +60,53 @@ func (cli *CLI) send(from, to string, amount int, nodeID string, mineNow bool) {
 
 	fmt.Println("Success!")
 }
+
+// walletSenders returns the wallets of the comma separated from addresses, or all of them when from is empty
+func walletSenders(wallets *Wallets, from string) []*Wallet {
+	addresses := wallets.GetAddresses()
+	if from != "" {
+		addresses = strings.Split(from, ",")
+	}
+
+	var senders []*Wallet
+	for _, address := range addresses {
+		decoded, err := DecodeAddress(strings.TrimSpace(address))
+		if err != nil {
+			log.Panic(err)
+		}
+		key, _ := wallets.FindAddress(decoded)
+		wallet, ok := wallets.Wallets[key]
+		if !ok {
+			log.Panicf("ERROR: Address %s is not in the wallet", address)
+		}
+		senders = append(senders, wallet)
+	}
+
+	return senders
+}
+
+// sendRecipients collects the recipients given either as a single address with an amount,
+// a list of ADDRESS:AMOUNT pairs or a CSV/JSON file
+func sendRecipients(to string, amount int, recipientsFile string) []Recipient {
+	var recipients []Recipient
+	var err error
+
+	switch {
+	case recipientsFile != "":
+		recipients, err = LoadRecipients(recipientsFile)
+	case strings.Contains(to, ":"):
+		recipients, err = ParseRecipients(to)
+	default:
+		var address Address
+		address, err = DecodeAddress(to)
+		if err == nil {
+			recipients = []Recipient{{address, amount}}
+			err = validateRecipients(recipients)
+		}
+	}
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return recipients
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+)
+
+// sendRawTx broadcasts the transaction in file, or mines it right away paying the reward to miner
+func (cli *CLI) sendRawTx(file, miner, nodeID string, mineNow bool) {
+	var minerAddress Address
+	if mineNow {
+		var err error
+		minerAddress, err = DecodeAddress(miner)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	ptx := LoadPartialTransaction(file)
+	tx := ptx.Tx
+
+	// A running node holds the database, it checks and sends the transaction
+	if !mineNow && callNode(nodeID, "sendrawtransaction", nil, hex.EncodeToString(tx.Serialize())) {
+		fmt.Println("Success!")
+		return
+	}
+
+	bc := NewBlockchain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
+
+	if !bc.VerifyTransaction(&tx) {
+		log.Panic("ERROR: Transaction is not fully signed")
+	}
+	if !bc.IsTransactionFinal(&tx, bc.GetBestHeight()+1, time.Now().Unix()) {
+		log.Panic("ERROR: Transaction is locked, try again later")
+	}
+
+	if mineNow {
+		cbTx := NewCoinbaseTX(minerAddress, "")
+		txs := []*Transaction{cbTx, &tx}
+
+		newBlock := bc.MineBlock(txs)
+		UTXOSet.Update(newBlock)
+	} else {
+		sendTx(knownNodes[0], &tx)
+	}
+
+	fmt.Println("Success!")
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+func (cli *CLI) signRawTx(file, nodeID string) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	cli.unlockWallets(wallets)
+
+	ptx := LoadPartialTransaction(file)
+	signed := 0
+
+	for _, wallet := range wallets.Wallets {
+		signed += ptx.Sign(wallet.PrivateKey)
+	}
+
+	if signed == 0 {
+		log.Panic("ERROR: No key in the wallet can sign this transaction")
+	}
+	ptx.SaveToFile(file)
+
+	fmt.Printf("Added %d signature(s) to %s\n", signed, file)
+	if ptx.IsComplete() {
+		fmt.Println("Transaction is fully signed and ready to be sent.")
+	} else {
+		fmt.Println("Transaction needs more signatures.")
+	}
+}
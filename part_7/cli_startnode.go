@@ -5,14 +5,26 @@ import (
 	"log"
 )
 
-func (cli *CLI) startNode(nodeID, minerAddress string) {
+func (cli *CLI) startNode(nodeID, minerAddress, restAddress string, prune, dbCache int64) {
 	fmt.Printf("Starting node %s\n", nodeID)
 	if len(minerAddress) > 0 {
-		if ValidateAddress(minerAddress) {
-			fmt.Println("Mining is on. Address to receive rewards: ", minerAddress)
-		} else {
-			log.Panic("Wrong miner address!")
+		_, err := DecodeAddress(minerAddress)
+		if err != nil {
+			log.Panic(err)
 		}
+		fmt.Println("Mining is on. Address to receive rewards: ", minerAddress)
 	}
-	StartServer(nodeID, minerAddress)
+	if prune < 0 {
+		log.Panic("ERROR: Prune size must not be negative")
+	}
+	if prune > 0 {
+		fmt.Printf("Pruning is on. Block files are kept under %d MiB, the last %d blocks are always kept\n", prune, minBlocksToKeep)
+	}
+	if dbCache <= 0 {
+		log.Panic("ERROR: UTXO cache size must be positive")
+	}
+	if restAddress != "" {
+		fmt.Printf("The REST API and WebSocket feed are served on %s, they need no credentials\n", restAddress)
+	}
+	StartServer(nodeID, minerAddress, restAddress, prune<<20, dbCache<<20)
 }
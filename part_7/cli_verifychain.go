@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func (cli *CLI) verifyChain(level int, nodeID string) {
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+
+	checked, err := bc.VerifyChain(level)
+	if err != nil {
+		fmt.Printf("%d blocks are consistent, then:\n%s\n", checked, err)
+		bc.db.Close()
+		os.Exit(1)
+	}
+
+	fmt.Printf("Done! %d blocks are consistent at level %d.\n", checked, level)
+}
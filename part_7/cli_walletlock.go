@@ -0,0 +1,9 @@
+package main
+
+import "fmt"
+
+func (cli *CLI) walletLock(nodeID string) {
+	sendWalletLock(fmt.Sprintf("localhost:%s", nodeID))
+
+	fmt.Printf("Asked node %s to lock its wallet\n", nodeID)
+}
@@ -0,0 +1,10 @@
+package main
+
+import "fmt"
+
+func (cli *CLI) walletPassphrase(timeout int, nodeID string) {
+	passphrase := readPassphrase("Wallet passphrase: ")
+	sendWalletUnlock(fmt.Sprintf("localhost:%s", nodeID), passphrase, timeout)
+
+	fmt.Printf("Asked node %s to unlock its wallet for %d seconds\n", nodeID, timeout)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+const defaultCoinSelection = "bnb"
+
+// bnbMaxTries bounds the branch and bound search before falling back to largest-first
+const bnbMaxTries = 100000
+
+var errNotEnoughFunds = errors.New("ERROR: Not enough funds")
+
+// UnspentOutput is an unspent transaction output together with its outpoint
+type UnspentOutput struct {
+	TxID   []byte
+	Vout   int
+	Output TXOutput
+}
+
+// CoinSelector picks unspent outputs worth at least amount
+type CoinSelector func(utxos []UnspentOutput, amount int) ([]UnspentOutput, error)
+
+var coinSelectors = map[string]CoinSelector{
+	"largest":   selectLargestFirst,
+	"bnb":       selectBranchAndBound,
+	"mininputs": selectMinInputs,
+	"privacy":   selectPrivacy,
+}
+
+// GetCoinSelector returns a coin selection strategy by its name
+func GetCoinSelector(name string) (CoinSelector, error) {
+	selector, ok := coinSelectors[name]
+	if !ok {
+		var names []string
+		for name := range coinSelectors {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		return nil, fmt.Errorf("Unknown coin selection %q, use one of: %s", name, strings.Join(names, ", "))
+	}
+
+	return selector, nil
+}
+
+// excludeOutputs wraps selector so it never picks the outputs in spent, keyed by their chainstate key
+func excludeOutputs(selector CoinSelector, spent map[string]bool) CoinSelector {
+	return func(utxos []UnspentOutput, amount int) ([]UnspentOutput, error) {
+		var available []UnspentOutput
+		for _, utxo := range utxos {
+			if !spent[string(outpointKey(utxo.TxID, utxo.Vout))] {
+				available = append(available, utxo)
+			}
+		}
+
+		return selector(available, amount)
+	}
+}
+
+// selectLargestFirst spends the biggest outputs first
+func selectLargestFirst(utxos []UnspentOutput, amount int) ([]UnspentOutput, error) {
+	sorted := sortedByValue(utxos)
+
+	return accumulate(sorted, amount)
+}
+
+// selectBranchAndBound looks for a set of outputs matching amount exactly, so no change output is needed.
+// When there is none it falls back to largest-first
+func selectBranchAndBound(utxos []UnspentOutput, amount int) ([]UnspentOutput, error) {
+	sorted := sortedByValue(utxos)
+
+	// remaining[i] is the sum of the outputs from i on, used to prune branches that can't reach amount
+	remaining := make([]int, len(sorted)+1)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + sorted[i].Output.Value
+	}
+
+	var selected []int
+	tries := 0
+
+	var search func(i, total int) bool
+	search = func(i, total int) bool {
+		tries++
+		if total == amount {
+			return true
+		}
+		if total > amount || i == len(sorted) || total+remaining[i] < amount || tries > bnbMaxTries {
+			return false
+		}
+
+		selected = append(selected, i)
+		if search(i+1, total+sorted[i].Output.Value) {
+			return true
+		}
+		selected = selected[:len(selected)-1]
+
+		return search(i+1, total)
+	}
+
+	if search(0, 0) {
+		var result []UnspentOutput
+		for _, i := range selected {
+			result = append(result, sorted[i])
+		}
+
+		return result, nil
+	}
+
+	return accumulate(sorted, amount)
+}
+
+// selectMinInputs spends the smallest single output that covers amount, or as few outputs as possible
+func selectMinInputs(utxos []UnspentOutput, amount int) ([]UnspentOutput, error) {
+	sorted := sortedByValue(utxos)
+
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if sorted[i].Output.Value >= amount {
+			return []UnspentOutput{sorted[i]}, nil
+		}
+	}
+
+	return accumulate(sorted, amount)
+}
+
+// selectPrivacy picks outputs in random order, so spends don't follow a pattern that links them
+func selectPrivacy(utxos []UnspentOutput, amount int) ([]UnspentOutput, error) {
+	shuffled := make([]UnspentOutput, len(utxos))
+	copy(shuffled, utxos)
+	rand.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	return accumulate(shuffled, amount)
+}
+
+// sortedByValue returns a copy of utxos sorted from the biggest to the smallest value
+func sortedByValue(utxos []UnspentOutput) []UnspentOutput {
+	sorted := make([]UnspentOutput, len(utxos))
+	copy(sorted, utxos)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Output.Value > sorted[j].Output.Value
+	})
+
+	return sorted
+}
+
+// accumulate takes outputs in order until they are worth at least amount
+func accumulate(utxos []UnspentOutput, amount int) ([]UnspentOutput, error) {
+	var selected []UnspentOutput
+	total := 0
+
+	for _, utxo := range utxos {
+		if total >= amount {
+			break
+		}
+		selected = append(selected, utxo)
+		total += utxo.Output.Value
+	}
+
+	if total < amount {
+		return nil, errNotEnoughFunds
+	}
+
+	return selected, nil
+}
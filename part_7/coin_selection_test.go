@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testUTXOs(values ...int) []UnspentOutput {
+	var utxos []UnspentOutput
+	for i, value := range values {
+		utxos = append(utxos, UnspentOutput{[]byte{byte(i)}, 0, TXOutput{Value: value}})
+	}
+
+	return utxos
+}
+
+func selectedValues(utxos []UnspentOutput) []int {
+	var values []int
+	for _, utxo := range utxos {
+		values = append(values, utxo.Output.Value)
+	}
+
+	return values
+}
+
+func TestCoinSelectors(t *testing.T) {
+	utxos := testUTXOs(1, 7, 3, 5, 10)
+
+	selected, err := selectLargestFirst(utxos, 12)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{10, 7}, selectedValues(selected), "Largest outputs are spent first")
+
+	selected, err = selectBranchAndBound(utxos, 9)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{5, 3, 1}, selectedValues(selected), "Exact match needs no change")
+
+	selected, err = selectBranchAndBound(testUTXOs(4, 6), 5)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{6}, selectedValues(selected), "Falls back to largest-first without exact match")
+
+	selected, err = selectMinInputs(utxos, 6)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{7}, selectedValues(selected), "Smallest single output covering the amount")
+
+	selected, err = selectPrivacy(utxos, 20)
+	assert.Nil(t, err)
+	total := 0
+	for _, value := range selectedValues(selected) {
+		total += value
+	}
+	assert.True(t, total >= 20)
+
+	for name := range coinSelectors {
+		selector, err := GetCoinSelector(name)
+		assert.Nil(t, err)
+		_, err = selector(utxos, 27)
+		assert.Equal(t, errNotEnoughFunds, err, name)
+	}
+
+	_, err = GetCoinSelector("random")
+	assert.NotNil(t, err)
+}
+
+func TestExcludeOutputs(t *testing.T) {
+	utxos := testUTXOs(1, 7, 3)
+	spent := map[string]bool{string(outpointKey(utxos[1].TxID, utxos[1].Vout)): true}
+
+	selected, err := excludeOutputs(selectLargestFirst, spent)(utxos, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{3, 1}, selectedValues(selected), "Spent outputs are never picked")
+
+	_, err = excludeOutputs(selectLargestFirst, spent)(utxos, 5)
+	assert.Equal(t, errNotEnoughFunds, err)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// eventBufferSize is how many events a WebSocket client may fall behind before it's dropped
+const eventBufferSize = 64
+
+const eventWriteTimeout = 10 * time.Second
+
+// Types of chainEvent
+const (
+	eventTip     = "tip"
+	eventMempool = "mempool"
+	eventReorg   = "reorg"
+)
+
+// chainEvent is a message of the WebSocket feed. A reorg lists the hashes of the blocks that left the main
+// chain from the old tip down, and of the ones that joined it up to the new tip; its tip event follows
+type chainEvent struct {
+	Type         string     `json:"type"`
+	Block        *blockJSON `json:"block,omitempty"`
+	Tx           *txJSON    `json:"tx,omitempty"`
+	Disconnected []string   `json:"disconnected,omitempty"`
+	Connected    []string   `json:"connected,omitempty"`
+}
+
+// eventFeed pushes chain events to the WebSocket clients of a node, publishing to a nil feed does nothing
+type eventFeed struct {
+	mu      sync.Mutex
+	clients map[chan chainEvent]bool
+}
+
+var upgrader = websocket.Upgrader{
+	// The feed is read-only, pages of any origin may follow it
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+func newEventFeed() *eventFeed {
+	return &eventFeed{clients: make(map[chan chainEvent]bool)}
+}
+
+func (f *eventFeed) subscribe() chan chainEvent {
+	events := make(chan chainEvent, eventBufferSize)
+
+	f.mu.Lock()
+	f.clients[events] = true
+	f.mu.Unlock()
+
+	return events
+}
+
+// unsubscribe closes events unless the feed already dropped it
+func (f *eventFeed) unsubscribe(events chan chainEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.clients[events] {
+		delete(f.clients, events)
+		close(events)
+	}
+}
+
+// publish never blocks the node, clients that don't keep up are dropped and have to reconnect
+func (f *eventFeed) publish(event chainEvent) {
+	if f == nil {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for events := range f.clients {
+		select {
+		case events <- event:
+		default:
+			delete(f.clients, events)
+			close(events)
+		}
+	}
+}
+
+func (f *eventFeed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Subscribing first, clients get every event published once the handshake is over
+	events := f.subscribe()
+	defer f.unsubscribe(events)
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already answered the request
+		return
+	}
+	defer conn.Close()
+
+	// Clients only listen, reading notices when they go away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"), time.Now().Add(eventWriteTimeout))
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+			err := conn.WriteJSON(event)
+			if err != nil {
+				fmt.Printf("Can't write to WebSocket client %s: %s\n", r.RemoteAddr, err)
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// publishTip tells the feed block became the tip of the chain, which was oldTip
+func (bc *Blockchain) publishTip(oldTip []byte, block *Block) {
+	if bc.events == nil {
+		return
+	}
+
+	if len(oldTip) > 0 && bytes.Compare(block.PrevBlockHash, oldTip) != 0 {
+		disconnected, connected := bc.forkBranches(oldTip, block.Hash)
+		bc.events.publish(chainEvent{Type: eventReorg, Disconnected: hexHashes(disconnected), Connected: hexHashes(connected)})
+	}
+
+	result := newBlockJSON(block)
+	bc.events.publish(chainEvent{Type: eventTip, Block: &result})
+}
+
+// publishTransaction tells the feed tx entered the mempool
+func (bc *Blockchain) publishTransaction(tx *Transaction) {
+	if bc.events == nil {
+		return
+	}
+
+	result := newTxJSON(tx)
+	bc.events.publish(chainEvent{Type: eventMempool, Tx: &result})
+}
+
+// forkBranches returns the hashes of the blocks from oldTip down to the fork point and the ones from above
+// the fork point up to newTip. It reads headers only, the blocks of a branch may be pruned
+func (bc *Blockchain) forkBranches(oldTip, newTip []byte) ([][]byte, [][]byte) {
+	header := func(hash []byte) BlockHeader {
+		header, err := bc.GetBlockHeader(hash)
+		if err != nil {
+			log.Panic(err)
+		}
+		return header
+	}
+
+	var disconnected, connected [][]byte
+	left, joined := header(oldTip), header(newTip)
+	for bytes.Compare(left.Hash, joined.Hash) != 0 {
+		if left.Height >= joined.Height {
+			disconnected = append(disconnected, left.Hash)
+			left = header(left.PrevBlockHash)
+		} else {
+			connected = append([][]byte{joined.Hash}, connected...)
+			joined = header(joined.PrevBlockHash)
+		}
+	}
+
+	return disconnected, connected
+}
+
+func hexHashes(hashes [][]byte) []string {
+	encoded := make([]string, len(hashes))
+	for i, hash := range hashes {
+		encoded[i] = hex.EncodeToString(hash)
+	}
+
+	return encoded
+}
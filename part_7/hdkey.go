@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// hardenedKeyStart is the first child index of hardened derivation
+const hardenedKeyStart = 0x80000000
+
+var masterKeySalt = []byte("Bitcoin seed")
+
+// ExtendedKey is a BIP32-style extended private key on the P256 curve
+type ExtendedKey struct {
+	Key       []byte
+	ChainCode []byte
+}
+
+// NewMasterKey derives the master extended key from a seed
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	mac := hmac.New(sha512.New, masterKeySalt)
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	key := sum[:32]
+	if !isValidPrivateKey(key) {
+		return nil, errors.New("Seed produces an invalid master key")
+	}
+
+	return &ExtendedKey{key, sum[32:]}, nil
+}
+
+// Child derives the child key with the given index, indices from hardenedKeyStart on are hardened
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	var data []byte
+
+	if index >= hardenedKeyStart {
+		data = append([]byte{0x00}, paddedKey(k.Key)...)
+	} else {
+		private := k.PrivateKey()
+		data = elliptic.MarshalCompressed(private.Curve, private.X, private.Y)
+	}
+	indexBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(indexBytes, index)
+	data = append(data, indexBytes...)
+
+	mac := hmac.New(sha512.New, k.ChainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	if !isValidPrivateKey(sum[:32]) {
+		return nil, fmt.Errorf("Child %d is invalid, use the next index", index)
+	}
+
+	n := elliptic.P256().Params().N
+	childKey := new(big.Int).SetBytes(sum[:32])
+	childKey.Add(childKey, new(big.Int).SetBytes(k.Key))
+	childKey.Mod(childKey, n)
+	if childKey.Sign() == 0 {
+		return nil, fmt.Errorf("Child %d is invalid, use the next index", index)
+	}
+
+	return &ExtendedKey{paddedKey(childKey.Bytes()), sum[32:]}, nil
+}
+
+// Derive follows a derivation path like m/44'/0'/0'/0/1 starting at k
+func (k *ExtendedKey) Derive(path string) (*ExtendedKey, error) {
+	parts := strings.Split(path, "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, fmt.Errorf("Derivation path %q must start with m", path)
+	}
+
+	key := k
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'")
+		index, err := strconv.ParseUint(strings.TrimSuffix(part, "'"), 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid derivation path %q", path)
+		}
+		if hardened {
+			index += hardenedKeyStart
+		}
+
+		key, err = key.Child(uint32(index))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+// PrivateKey returns the ECDSA private key of the extended key
+func (k *ExtendedKey) PrivateKey() ecdsa.PrivateKey {
+	return privateKeyFromBytes(k.Key)
+}
+
+func isValidPrivateKey(key []byte) bool {
+	d := new(big.Int).SetBytes(key)
+
+	return d.Sign() > 0 && d.Cmp(elliptic.P256().Params().N) < 0
+}
+
+// paddedKey left-pads a private key to 32 bytes
+func paddedKey(key []byte) []byte {
+	padded := make([]byte, 32)
+	copy(padded[32-len(key):], key)
+
+	return padded
+}
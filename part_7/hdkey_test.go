@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tyler-smith/go-bip39"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestExtendedKeyDerive(t *testing.T) {
+	master, err := NewMasterKey(bip39.NewSeed(testMnemonic, ""))
+	assert.Nil(t, err)
+
+	k1, err := master.Derive("m/44'/0'/0'/0/0")
+	assert.Nil(t, err)
+	k2, err := master.Derive("m/44'/0'/0'/0/0")
+	assert.Nil(t, err)
+	k3, err := master.Derive("m/44'/0'/0'/0/1")
+	assert.Nil(t, err)
+
+	assert.Equal(t, k1, k2, "Derivation is deterministic")
+	assert.NotEqual(t, k1.Key, k3.Key, "Siblings have different keys")
+
+	_, err = master.Derive("44'/0'")
+	assert.NotNil(t, err, "Paths start at m")
+}
+
+func TestWalletsDiscover(t *testing.T) {
+	dir, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(dir)
+
+	seed := bip39.NewSeed(testMnemonic, "")
+	original, _ := NewWallets("test")
+	assert.Nil(t, original.SetSeed(seed))
+
+	var used [][]byte
+	for i := 0; i < 3; i++ {
+		address := original.CreateWallet(base58Encoding)
+		used = append(used, HashPubKey(original.GetWallet(address).PublicKey))
+	}
+	original.SaveToFile("test")
+
+	loaded, err := NewWallets("test")
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, original.GetAddresses(), loaded.GetAddresses(), "Wallets survive the wallet file")
+
+	// Only the third address holds coins, the first two are restored too
+	restored, _ := NewWallets("restore")
+	assert.Nil(t, restored.SetSeed(seed))
+	restored.Discover(func(pubKeyHash []byte) bool {
+		return bytes.Compare(pubKeyHash, used[2]) == 0
+	})
+
+	assert.ElementsMatch(t, original.GetAddresses(), restored.GetAddresses())
+	assert.Equal(t, uint32(3), restored.NextIndex[receiveChain])
+	assert.Equal(t, uint32(0), restored.NextIndex[changeChain])
+}
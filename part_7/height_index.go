@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"log"
+)
+
+const heightIndexBucket = "heights"
+
+// HeightIndex maps the heights of the main chain to the hashes of its blocks
+type HeightIndex struct {
+	Blockchain *Blockchain
+}
+
+// Sync brings the height index up to the tip of the chain, it's built on first use for older databases
+func (hi HeightIndex) Sync() {
+	err := hi.Blockchain.db.Update(func(tx StoreTx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(heightIndexBucket))
+
+		return err
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	syncChainIndex(hi.Blockchain, hi)
+}
+
+// ConnectBlock records the height of a block added to the main chain
+func (hi HeightIndex) ConnectBlock(block *Block) {
+	err := hi.Blockchain.db.Update(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(heightIndexBucket))
+
+		err := b.Put(heightKey(block.Height), block.Hash)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		return b.Put([]byte("l"), block.Hash)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// DisconnectBlock removes the height of a block that left the main chain
+func (hi HeightIndex) DisconnectBlock(block *Block) {
+	err := hi.Blockchain.db.Update(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(heightIndexBucket))
+
+		if bytes.Compare(b.Get(heightKey(block.Height)), block.Hash) == 0 {
+			err := b.Delete(heightKey(block.Height))
+			if err != nil {
+				log.Panic(err)
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			return b.Delete([]byte("l"))
+		}
+
+		return b.Put([]byte("l"), block.PrevBlockHash)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// Hash returns the hash of the main chain block at height
+func (hi HeightIndex) Hash(height int) ([]byte, error) {
+	var hash []byte
+
+	err := hi.Blockchain.db.View(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(heightIndexBucket))
+		if b == nil {
+			return errors.New("Height index is missing")
+		}
+
+		hash = append(hash, b.Get(heightKey(height))...)
+		if len(hash) == 0 {
+			return errors.New("Block is not found.")
+		}
+
+		return nil
+	})
+
+	return hash, err
+}
+
+// BestHeight returns the highest height in the index, without reading the tip block
+func (hi HeightIndex) BestHeight() int {
+	height := 0
+
+	err := hi.Blockchain.db.View(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(heightIndexBucket))
+		if b == nil {
+			return errors.New("Height index is missing")
+		}
+
+		// Height keys are 4 bytes long, which sets them apart from "l"
+		c := b.Cursor()
+		for k, _ := c.Last(); k != nil; k, _ = c.Prev() {
+			if len(k) == 4 {
+				height = int(binary.BigEndian.Uint32(k))
+				break
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return height
+}
+
+func (hi HeightIndex) syncedHash() []byte {
+	return indexTip(hi.Blockchain.db, heightIndexBucket)
+}
+
+// heightKey encodes heights big-endian, so they're sorted in the bucket
+func heightKey(height int) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(height))
+}
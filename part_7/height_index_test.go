@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeightIndexReorg(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet.Address())
+	genesis := bc.tip
+	index := HeightIndex{bc}
+	mined := bc.MineBlock([]*Transaction{NewCoinbaseTX(wallet.Address(), "")})
+	index.Sync()
+
+	assert.Equal(t, 1, index.BestHeight())
+	hash, err := index.Hash(1)
+	assert.Nil(t, err)
+	assert.Equal(t, mined.Hash, hash)
+
+	// A longer branch from the genesis block takes over
+	side := NewBlock([]*Transaction{NewCoinbaseTX(wallet.Address(), "side")}, genesis, 1)
+	bc.AddBlock(side)
+	sideTip := NewBlock([]*Transaction{NewCoinbaseTX(wallet.Address(), "")}, side.Hash, 2)
+	bc.AddBlock(sideTip)
+	index.Sync()
+
+	assert.Equal(t, 2, index.BestHeight(), "The cursor skips the \"l\" key sorted after the heights")
+	for height, expected := range [][]byte{genesis, side.Hash, sideTip.Hash} {
+		hash, err := index.Hash(height)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, hash)
+	}
+
+	// The height of a block that isn't the indexed one at its height is kept
+	index.DisconnectBlock(mined)
+	hash, err = index.Hash(1)
+	assert.Nil(t, err)
+	assert.Equal(t, side.Hash, hash)
+
+	index.DisconnectBlock(sideTip)
+	assert.Equal(t, 1, index.BestHeight())
+	_, err = index.Hash(2)
+	assert.NotNil(t, err)
+	assert.Equal(t, side.Hash, index.syncedHash())
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+const maxMultisigKeys = 16
+
+// MultisigScript describes an M-of-N redeem script: Required signatures out of PubKeys
+type MultisigScript struct {
+	Required int
+	PubKeys  [][]byte
+}
+
+// NewMultisigScript creates a redeem script requiring m signatures of the given public keys
+func NewMultisigScript(m int, pubKeys [][]byte) (*MultisigScript, error) {
+	if len(pubKeys) == 0 || len(pubKeys) > maxMultisigKeys {
+		return nil, fmt.Errorf("Multisig needs between 1 and %d public keys", maxMultisigKeys)
+	}
+	if m < 1 || m > len(pubKeys) {
+		return nil, fmt.Errorf("Required signatures must be between 1 and %d", len(pubKeys))
+	}
+
+	for i, pubKey := range pubKeys {
+		if len(pubKey) == 0 || len(pubKey) > 255 {
+			return nil, fmt.Errorf("Public key %d has invalid length", i)
+		}
+		for _, other := range pubKeys[:i] {
+			if bytes.Compare(pubKey, other) == 0 {
+				return nil, errors.New("Duplicate public key in multisig")
+			}
+		}
+	}
+
+	return &MultisigScript{m, pubKeys}, nil
+}
+
+// Serialize encodes the script as M, N and length-prefixed public keys
+func (ms MultisigScript) Serialize() []byte {
+	data := []byte{byte(ms.Required), byte(len(ms.PubKeys))}
+
+	for _, pubKey := range ms.PubKeys {
+		data = append(data, byte(len(pubKey)))
+		data = append(data, pubKey...)
+	}
+
+	return data
+}
+
+// DeserializeMultisigScript decodes a redeem script
+func DeserializeMultisigScript(data []byte) (*MultisigScript, error) {
+	if len(data) < 2 {
+		return nil, errors.New("Redeem script is too short")
+	}
+
+	m := int(data[0])
+	n := int(data[1])
+	data = data[2:]
+
+	var pubKeys [][]byte
+	for i := 0; i < n; i++ {
+		if len(data) == 0 || len(data) < int(data[0])+1 {
+			return nil, errors.New("Redeem script is truncated")
+		}
+		keyLen := int(data[0])
+		pubKeys = append(pubKeys, data[1:keyLen+1])
+		data = data[keyLen+1:]
+	}
+	if len(data) != 0 {
+		return nil, errors.New("Redeem script has trailing data")
+	}
+
+	return NewMultisigScript(m, pubKeys)
+}
+
+// Hash returns the hash the script's outputs are locked with
+func (ms MultisigScript) Hash() []byte {
+	return HashPubKey(ms.Serialize())
+}
+
+// GetAddress returns the script hash address of the multisig
+func (ms MultisigScript) GetAddress() []byte {
+	return []byte(ms.Address().String())
+}
+
+// Address returns the decoded script hash address of the multisig
+func (ms MultisigScript) Address() Address {
+	return NewScriptHashAddress(ms.Hash())
+}
+
+// KeyIndex returns the position of pubKey in the script or -1
+func (ms MultisigScript) KeyIndex(pubKey []byte) int {
+	for i, key := range ms.PubKeys {
+		if bytes.Compare(key, pubKey) == 0 {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Verify checks that at least Required of the signatures are valid for data
+func (ms MultisigScript) Verify(data []byte, signatures [][]byte) bool {
+	if len(signatures) > len(ms.PubKeys) {
+		return false
+	}
+
+	valid := 0
+	for i, signature := range signatures {
+		if len(signature) == 0 {
+			continue
+		}
+		if verifySignature(ms.PubKeys[i], data, signature) {
+			valid++
+		}
+	}
+
+	return valid >= ms.Required
+}
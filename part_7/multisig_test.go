@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultisigScriptSerialize(t *testing.T) {
+	w1, w2 := NewWallet(), NewWallet()
+
+	script, err := NewMultisigScript(1, [][]byte{w1.PublicKey, w2.PublicKey})
+	assert.Nil(t, err)
+
+	decoded, err := DeserializeMultisigScript(script.Serialize())
+	assert.Nil(t, err)
+	assert.Equal(t, script, decoded, "Redeem script survives serialization")
+	assert.True(t, ValidateAddress(string(script.GetAddress())), "Multisig address is valid")
+
+	_, err = NewMultisigScript(3, [][]byte{w1.PublicKey, w2.PublicKey})
+	assert.NotNil(t, err, "Required can't exceed the number of keys")
+	_, err = NewMultisigScript(1, [][]byte{w1.PublicKey, w1.PublicKey})
+	assert.NotNil(t, err, "Keys must be unique")
+}
+
+func TestMultisigSignVerify(t *testing.T) {
+	w1, w2, w3 := NewWallet(), NewWallet(), NewWallet()
+	script, err := NewMultisigScript(2, [][]byte{w1.PublicKey, w2.PublicKey, w3.PublicKey})
+	assert.Nil(t, err)
+
+	funding := Transaction{[]byte("funding"), nil, []TXOutput{
+		*NewTXOutput(10, script.Address()),
+	}, 0}
+	assert.Equal(t, scriptHashOutput, funding.Vout[0].Type, "Output is locked to a script hash")
+	prevTXs := map[string]Transaction{hex.EncodeToString(funding.ID): funding}
+
+	spend := Transaction{nil, []TXInput{
+		{Txid: funding.ID, Vout: 0, RedeemScript: script.Serialize()},
+	}, []TXOutput{*NewTXOutput(10, w1.Address())}, 0}
+	spend.ID = spend.Hash()
+
+	assert.Equal(t, 1, spend.SignMultisig(w1.PrivateKey, prevTXs))
+	assert.False(t, spend.Verify(prevTXs), "One signature is not enough")
+
+	assert.Equal(t, 1, spend.SignMultisig(w3.PrivateKey, prevTXs))
+	assert.True(t, spend.Verify(prevTXs), "Two signatures satisfy 2-of-3")
+
+	outsider := NewWallet()
+	assert.Equal(t, 0, spend.SignMultisig(outsider.PrivateKey, prevTXs), "Unrelated keys don't sign")
+
+	spend.Vout[0].Value = 9
+	assert.False(t, spend.Verify(prevTXs), "Signatures commit to outputs")
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/gob"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"strings"
+)
+
+// PartialTransaction is an unsigned or partially signed transaction bundled with the outputs it spends,
+// so that it can be signed on a machine without a copy of the blockchain.
+// Collected signatures are kept in the inputs of Tx
+type PartialTransaction struct {
+	Tx          Transaction
+	PrevOutputs []TXOutput
+}
+
+// NewPartialTransaction looks up the outputs spent by tx and bundles them with it
+func NewPartialTransaction(tx *Transaction, bc *Blockchain) *PartialTransaction {
+	var prevOutputs []TXOutput
+
+	for _, vin := range tx.Vin {
+		prevTX, err := bc.FindTransaction(vin.Txid)
+		if err != nil {
+			log.Panic(err)
+		}
+		prevOutputs = append(prevOutputs, prevTX.Vout[vin.Vout])
+	}
+
+	return &PartialTransaction{*tx, prevOutputs}
+}
+
+// Sign adds privKey's signatures to every input it can sign and returns the number of inputs signed
+func (ptx *PartialTransaction) Sign(privKey ecdsa.PrivateKey) int {
+	prevTXs := ptx.prevTransactions()
+
+	return ptx.Tx.Sign(privKey, prevTXs) + ptx.Tx.SignMultisig(privKey, prevTXs)
+}
+
+// IsComplete checks whether all inputs carry enough valid signatures
+func (ptx *PartialTransaction) IsComplete() bool {
+	return ptx.Tx.Verify(ptx.prevTransactions())
+}
+
+// prevTransactions rebuilds the previous transactions map used by signing from PrevOutputs
+func (ptx *PartialTransaction) prevTransactions() map[string]Transaction {
+	if len(ptx.PrevOutputs) != len(ptx.Tx.Vin) {
+		log.Panic("ERROR: Previous outputs don't match transaction inputs")
+	}
+
+	prevTXs := make(map[string]Transaction)
+
+	for i, vin := range ptx.Tx.Vin {
+		txID := hex.EncodeToString(vin.Txid)
+		prevTX := prevTXs[txID]
+		prevTX.ID = vin.Txid
+
+		for len(prevTX.Vout) <= vin.Vout {
+			prevTX.Vout = append(prevTX.Vout, TXOutput{})
+		}
+		prevTX.Vout[vin.Vout] = ptx.PrevOutputs[i]
+
+		prevTXs[txID] = prevTX
+	}
+
+	return prevTXs
+}
+
+// Serialize serializes PartialTransaction
+func (ptx PartialTransaction) Serialize() []byte {
+	var encoded bytes.Buffer
+
+	enc := gob.NewEncoder(&encoded)
+	err := enc.Encode(ptx)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return encoded.Bytes()
+}
+
+// DeserializePartialTransaction deserializes a PartialTransaction
+func DeserializePartialTransaction(data []byte) PartialTransaction {
+	var ptx PartialTransaction
+
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	err := decoder.Decode(&ptx)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return ptx
+}
+
+// SaveToFile writes the hex-encoded PartialTransaction to a file
+func (ptx PartialTransaction) SaveToFile(path string) {
+	data := []byte(hex.EncodeToString(ptx.Serialize()))
+
+	// the file holds the signatures collected so far and is kept private like the wallet file
+	writePrivateFile(path, data)
+}
+
+// LoadPartialTransaction reads a PartialTransaction written by SaveToFile
+func LoadPartialTransaction(path string) PartialTransaction {
+	fileContent, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	data, err := hex.DecodeString(strings.TrimSpace(string(fileContent)))
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return DeserializePartialTransaction(data)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartialTransactionSign(t *testing.T) {
+	alice, bob := NewWallet(), NewWallet()
+
+	funding := Transaction{[]byte("funding"), nil, []TXOutput{
+		*NewTXOutput(3, bob.Address()),
+		*NewTXOutput(7, alice.Address()),
+	}, 0}
+	tx := Transaction{nil, []TXInput{
+		{Txid: funding.ID, Vout: 1},
+	}, []TXOutput{*NewTXOutput(7, bob.Address())}, 0}
+	tx.ID = tx.Hash()
+
+	ptx := PartialTransaction{tx, []TXOutput{funding.Vout[1]}}
+	ptx = DeserializePartialTransaction(ptx.Serialize())
+	assert.False(t, ptx.IsComplete(), "Unsigned transaction is not complete")
+
+	assert.Equal(t, 0, ptx.Sign(bob.PrivateKey), "Keys of other addresses don't sign")
+	assert.Equal(t, 1, ptx.Sign(alice.PrivateKey))
+	assert.True(t, ptx.IsComplete(), "Transaction is signed without the blockchain")
+	assert.Equal(t, alice.PublicKey, ptx.Tx.Vin[0].PubKey, "Signing fills in the public key")
+}
+
+func TestPartialTransactionSaveToFile(t *testing.T) {
+	tx := Transaction{nil, []TXInput{{Txid: []byte("funding"), Vout: 0}}, []TXOutput{*NewTXOutput(7, NewWallet().Address())}, 0}
+	tx.ID = tx.Hash()
+	ptx := PartialTransaction{tx, []TXOutput{tx.Vout[0]}}
+
+	path := filepath.Join(t.TempDir(), "tx.hex")
+	err := ioutil.WriteFile(path, nil, 0644)
+	assert.Nil(t, err)
+
+	ptx.SaveToFile(path)
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "An existing file is replaced by a private one")
+	assert.Equal(t, ptx.Tx.ID, LoadPartialTransaction(path).Tx.ID)
+}
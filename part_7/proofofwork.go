@@ -18,6 +18,8 @@ const targetBits = 16
 type ProofOfWork struct {
 	block  *Block
 	target *big.Int
+	// txHash is the Merkle root of the block's transactions
+	txHash []byte
 }
 
 // NewProofOfWork builds and returns a ProofOfWork
@@ -25,7 +27,18 @@ func NewProofOfWork(b *Block) *ProofOfWork {
 	target := big.NewInt(1)
 	target.Lsh(target, uint(256-targetBits))
 
-	pow := &ProofOfWork{b, target}
+	pow := &ProofOfWork{b, target, b.HashTransactions()}
+
+	return pow
+}
+
+// NewHeaderProofOfWork builds the ProofOfWork of a block known only by its header
+func NewHeaderProofOfWork(h BlockHeader) *ProofOfWork {
+	target := big.NewInt(1)
+	target.Lsh(target, uint(256-targetBits))
+
+	b := &Block{Timestamp: h.Timestamp, PrevBlockHash: h.PrevBlockHash, Hash: h.Hash, Nonce: h.Nonce, Height: h.Height}
+	pow := &ProofOfWork{b, target, h.TxHash}
 
 	return pow
 }
@@ -34,7 +47,7 @@ func (pow *ProofOfWork) prepareData(nonce int) []byte {
 	data := bytes.Join(
 		[][]byte{
 			pow.block.PrevBlockHash,
-			pow.block.HashTransactions(),
+			pow.txHash,
 			IntToHex(pow.block.Timestamp),
 			IntToHex(int64(targetBits)),
 			IntToHex(int64(nonce)),
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+)
+
+// minBlocksToKeep is the number of blocks below the tip a pruned node keeps, reorgs can't go deeper than this
+const minBlocksToKeep = 288
+
+// pruneHeightKey holds in the block index the height below which blocks may have been pruned
+var pruneHeightKey = []byte("p")
+
+var errBlockPruned = errors.New("Block is pruned, only its header is kept")
+
+// prunableFile is what the block index knows about the blocks of a block file
+type prunableFile struct {
+	maxHeight int
+	hashes    [][]byte
+}
+
+// Prune deletes the oldest block files until they take no more than target bytes, never touching
+// the last keep blocks. Headers stay in the block index, the chainstate and indexes stay as they are.
+// It returns the number of files deleted
+func (bc *Blockchain) Prune(target int64, keep int) (int, error) {
+	files := make(map[int]*prunableFile)
+
+	err := bc.db.View(func(tx StoreTx) error {
+		return tx.Bucket([]byte(blockIndexBucket)).ForEach(func(k, v []byte) error {
+			// "l", "n", "p" and "s" aren't block hashes
+			if len(k) == 1 {
+				return nil
+			}
+
+			entry := deserializeBlockIndexEntry(v)
+			if entry.Status&blockHaveData == 0 {
+				return nil
+			}
+
+			file, ok := files[entry.Pos.File]
+			if !ok {
+				file = &prunableFile{}
+				files[entry.Pos.File] = file
+			}
+			if entry.Header.Height > file.maxHeight {
+				file.maxHeight = entry.Header.Height
+			}
+			file.hashes = append(file.hashes, append([]byte{}, k...))
+
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	numbers, err := bc.blocks.Numbers()
+	if err != nil {
+		return 0, err
+	}
+
+	sizes := make(map[int]int64)
+	var total int64
+	for _, number := range numbers {
+		sizes[number], err = bc.blocks.Size(number)
+		if err != nil {
+			return 0, err
+		}
+		total += sizes[number]
+	}
+
+	lastPrunable := bc.GetBestHeight() - keep
+	removed := 0
+	for _, number := range numbers {
+		if total <= target {
+			break
+		}
+
+		file, ok := files[number]
+		if ok && file.maxHeight > lastPrunable || number == numbers[len(numbers)-1] {
+			continue
+		}
+
+		// The index stops pointing to the blocks before the file goes away
+		if ok {
+			bc.markPruned(file)
+		}
+
+		err = bc.blocks.Remove(number)
+		if err != nil {
+			return removed, err
+		}
+		total -= sizes[number]
+		removed++
+	}
+
+	return removed, nil
+}
+
+// markPruned clears the data flag of the blocks of a pruned file and raises the prune height past them
+func (bc *Blockchain) markPruned(file *prunableFile) {
+	err := bc.db.Update(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(blockIndexBucket))
+
+		for _, hash := range file.hashes {
+			entry := deserializeBlockIndexEntry(b.Get(hash))
+			entry.Status &^= blockHaveData
+
+			err := b.Put(hash, entry.serialize())
+			if err != nil {
+				return err
+			}
+		}
+
+		if file.maxHeight+1 <= bc.pruneHeight(b) {
+			return nil
+		}
+
+		return b.Put(pruneHeightKey, binary.BigEndian.AppendUint32(nil, uint32(file.maxHeight+1)))
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// PruneHeight returns the height below which blocks may have been pruned, 0 when nothing was
+func (bc *Blockchain) PruneHeight() int {
+	height := 0
+
+	err := bc.db.View(func(tx StoreTx) error {
+		height = bc.pruneHeight(tx.Bucket([]byte(blockIndexBucket)))
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return height
+}
+
+func (bc *Blockchain) pruneHeight(b StoreBucket) int {
+	value := b.Get(pruneHeightKey)
+	if value == nil {
+		return 0
+	}
+
+	return int(binary.BigEndian.Uint32(value))
+}
+
+// requireAllBlocks stops commands that replay the whole chain, a pruned node doesn't have the blocks to do it
+func (bc *Blockchain) requireAllBlocks() {
+	if height := bc.PruneHeight(); height > 0 {
+		log.Panicf("Blocks below height %d are pruned, this needs a node with all the blocks", height)
+	}
+}
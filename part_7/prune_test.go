@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrune(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet.Address())
+	// Every block goes to a file of its own
+	bc.blocks.maxSize = 1
+
+	cbtx := NewCoinbaseTX(wallet.Address(), "")
+	bc.MineBlock([]*Transaction{cbtx})
+	for i := 0; i < 4; i++ {
+		bc.MineBlock([]*Transaction{NewCoinbaseTX(wallet.Address(), "")})
+	}
+	assert.Equal(t, 5, bc.GetBestHeight())
+
+	removed, err := bc.Prune(0, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, removed, "Blocks 0 to 3 are pruned, the last 2 are kept")
+	assert.Equal(t, 4, bc.PruneHeight())
+
+	_, err = bc.GetBlockByHeight(3)
+	assert.True(t, errors.Is(err, errBlockPruned))
+	_, err = bc.FindTransaction(cbtx.ID)
+	assert.True(t, errors.Is(err, errBlockPruned), "Transactions of pruned blocks can't be read")
+
+	block, err := bc.GetBlockByHeight(4)
+	assert.Nil(t, err)
+	header, err := bc.GetBlockHeader(block.PrevBlockHash)
+	assert.Nil(t, err, "Headers of pruned blocks are kept")
+	assert.Equal(t, 3, header.Height)
+
+	assert.Len(t, bc.GetBlockHashes(), 2, "Only blocks with data are offered to peers")
+
+	removed, err = bc.Prune(0, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, removed)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Recipient is an address paid by a transaction and the amount it gets
+type Recipient struct {
+	Address Address `json:"address"`
+	Amount  int     `json:"amount"`
+}
+
+// ParseRecipients parses a comma separated list of ADDRESS:AMOUNT pairs
+func ParseRecipients(list string) ([]Recipient, error) {
+	var recipients []Recipient
+
+	for _, pair := range strings.Split(list, ",") {
+		parts := strings.Split(strings.TrimSpace(pair), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Recipient %q must be ADDRESS:AMOUNT", pair)
+		}
+
+		recipient, err := newRecipient(parts[0], parts[1])
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	return recipients, nil
+}
+
+// LoadRecipients reads recipients from a JSON file with a list of {"address", "amount"} objects,
+// or from a CSV file with address,amount rows
+func LoadRecipients(path string) ([]Recipient, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var recipients []Recipient
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.Unmarshal(content, &recipients)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		reader := csv.NewReader(strings.NewReader(string(content)))
+		reader.FieldsPerRecord = 2
+		reader.TrimLeadingSpace = true
+
+		rows, err := reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			recipient, err := newRecipient(row[0], row[1])
+			if err != nil {
+				return nil, err
+			}
+			recipients = append(recipients, recipient)
+		}
+	}
+
+	return recipients, validateRecipients(recipients)
+}
+
+// validateRecipients checks that there is at least one recipient and all of them can be paid
+func validateRecipients(recipients []Recipient) error {
+	if len(recipients) == 0 {
+		return errors.New("No recipients")
+	}
+
+	for _, recipient := range recipients {
+		if recipient.Address.Hash == nil {
+			return errors.New("Recipient address is missing")
+		}
+		if recipient.Amount <= 0 {
+			return fmt.Errorf("Amount for %s must be positive", recipient.Address)
+		}
+	}
+
+	return nil
+}
+
+// totalAmount returns the sum paid to all recipients
+func totalAmount(recipients []Recipient) int {
+	total := 0
+	for _, recipient := range recipients {
+		total += recipient.Amount
+	}
+
+	return total
+}
+
+func newRecipient(address, amount string) (Recipient, error) {
+	decoded, err := DecodeAddress(strings.TrimSpace(address))
+	if err != nil {
+		return Recipient{}, err
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(amount))
+	if err != nil {
+		return Recipient{}, fmt.Errorf("Invalid amount %q for %s", amount, address)
+	}
+
+	recipient := Recipient{decoded, value}
+
+	return recipient, validateRecipients([]Recipient{recipient})
+}
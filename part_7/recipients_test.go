@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRecipients(t *testing.T) {
+	wa, wb := NewWallet(), NewWallet()
+	a, b := wa.Address().String(), wb.Address().String()
+
+	recipients, err := ParseRecipients(a + ":5, " + b + ":7")
+	assert.Nil(t, err)
+	assert.Equal(t, []Recipient{{wa.Address(), 5}, {wb.Address(), 7}}, recipients)
+	assert.Equal(t, 12, totalAmount(recipients))
+
+	_, err = ParseRecipients(a + ":0")
+	assert.NotNil(t, err, "Amounts must be positive")
+	_, err = ParseRecipients("nope:1")
+	assert.NotNil(t, err, "Addresses must be valid")
+	_, err = ParseRecipients(a)
+	assert.NotNil(t, err, "Amount is required")
+}
+
+func TestLoadRecipients(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recipients")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	wa, wb := NewWallet(), NewWallet()
+	a, b := wa.Address().String(), wb.Address().String()
+	want := []Recipient{{wa.Address(), 3}, {wb.Address(), 4}}
+
+	csvFile := filepath.Join(dir, "payroll.csv")
+	assert.Nil(t, ioutil.WriteFile(csvFile, []byte(a+",3\n"+b+", 4\n"), 0644))
+	recipients, err := LoadRecipients(csvFile)
+	assert.Nil(t, err)
+	assert.Equal(t, want, recipients)
+
+	jsonFile := filepath.Join(dir, "payroll.json")
+	json := `[{"address": "` + a + `", "amount": 3}, {"address": "` + b + `", "amount": 4}]`
+	assert.Nil(t, ioutil.WriteFile(jsonFile, []byte(json), 0644))
+	recipients, err = LoadRecipients(jsonFile)
+	assert.Nil(t, err)
+	assert.Equal(t, want, recipients)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// restServer answers the read-only REST API of a node for explorers, unlike JSON-RPC it needs no cookie
+type restServer struct {
+	bc *Blockchain
+}
+
+// restHandler returns what a GET of path is answered with, path has the route's prefix cut off
+type restHandler func(s *restServer, path string) (interface{}, error)
+
+// restRoutes are the REST endpoints by path prefix
+var restRoutes = map[string]restHandler{
+	"/address/":      restAddressUTXOs,
+	"/block/":        restBlock,
+	"/block-height/": restBlockHeight,
+	"/mempool":       restMempool,
+	"/tx/":           restTransaction,
+}
+
+// utxoJSON is an entry of /address/{addr}/utxos
+type utxoJSON struct {
+	TxID     string `json:"txid"`
+	Vout     int    `json:"vout"`
+	Value    int    `json:"value"`
+	Height   int    `json:"height"`
+	Coinbase bool   `json:"coinbase"`
+}
+
+// mempoolJSON is the answer of /mempool
+type mempoolJSON struct {
+	mempoolInfo
+	Transactions []txJSON `json:"tx"`
+}
+
+// startRESTServer serves the REST API and the WebSocket feed on an address of their own, for explorers on
+// other hosts. JSON-RPC stays on localhost
+func startRESTServer(address string, bc *Blockchain) {
+	ln, err := net.Listen(protocol, address)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	mux := http.NewServeMux()
+	handleREST(mux, bc)
+
+	fmt.Printf("REST API is listening on %s\n", ln.Addr())
+	go http.Serve(ln, mux)
+}
+
+// handleREST adds the REST endpoints and the WebSocket feed of bc to mux
+func handleREST(mux *http.ServeMux, bc *Blockchain) {
+	s := &restServer{bc}
+	for prefix, handler := range restRoutes {
+		mux.Handle(prefix, s.route(prefix, handler))
+	}
+	mux.Handle("/ws", bc.events)
+}
+
+func (s *restServer) route(prefix string, handler restHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, restErrorJSON("The REST API is read-only"))
+			return
+		}
+
+		result, status, err := s.call(handler, strings.TrimPrefix(r.URL.Path, prefix))
+		if err != nil {
+			writeJSON(w, status, restErrorJSON(err.Error()))
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	})
+}
+
+// call runs a handler. Bad paths are 400 errors, failed lookups 404 and the panics the rest of the node
+// reports errors with 500
+func (s *restServer) call(handler restHandler, path string) (result interface{}, status int, err error) {
+	nodeLock.Lock()
+	defer nodeLock.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			result, status, err = nil, http.StatusInternalServerError, fmt.Errorf("%s", strings.TrimPrefix(fmt.Sprint(r), "ERROR: "))
+		}
+	}()
+
+	result, err = handler(s, path)
+	if err != nil {
+		if e, ok := err.(*rpcError); ok && e.Code == rpcInvalidParams {
+			return nil, http.StatusBadRequest, err
+		}
+
+		return nil, http.StatusNotFound, err
+	}
+
+	return result, http.StatusOK, nil
+}
+
+func restErrorJSON(message string) map[string]string {
+	return map[string]string{"error": message}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		fmt.Printf("Can't write the REST response: %s\n", err)
+	}
+}
+
+// /block/{hash}
+func restBlock(s *restServer, path string) (interface{}, error) {
+	hash, err := decodeHashParam(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := s.bc.GetBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBlockJSON(&block), nil
+}
+
+// /block-height/{n}: the block of the main chain at height n
+func restBlockHeight(s *restServer, path string) (interface{}, error) {
+	height, err := strconv.Atoi(path)
+	if err != nil || height < 0 {
+		return nil, &rpcError{rpcInvalidParams, fmt.Sprintf("%q is not a block height", path)}
+	}
+
+	block, err := s.bc.GetBlockByHeight(height)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBlockJSON(&block), nil
+}
+
+// /tx/{id}: a transaction of the chain or the mempool
+func restTransaction(s *restServer, path string) (interface{}, error) {
+	txID, err := decodeHashParam(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, block, err := findTransaction(s.bc, txID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := newTxJSON(tx)
+	if block != nil {
+		result.BlockHash = hex.EncodeToString(block.Hash)
+	}
+
+	return result, nil
+}
+
+// /address/{addr}/utxos: the unspent outputs of an address
+func restAddressUTXOs(s *restServer, path string) (interface{}, error) {
+	addressParam, rest, _ := strings.Cut(path, "/")
+	if rest != "utxos" {
+		return nil, fmt.Errorf("%q is not found", "/address/"+path)
+	}
+	address, err := DecodeAddress(addressParam)
+	if err != nil {
+		return nil, &rpcError{rpcInvalidParams, err.Error()}
+	}
+
+	UTXOs := []utxoJSON{}
+	UTXOSet := UTXOSet{s.bc}
+
+	// The address index finds the outputs without going through the whole UTXO set
+	keys, err := AddressIndex{s.bc}.UnspentOutputs(address.Hash)
+	if err == nil {
+		for _, key := range keys {
+			entry, ok := UTXOSet.entry(key)
+			if !ok {
+				continue
+			}
+			txID, vout := splitOutpointKey(key)
+			UTXOs = append(UTXOs, utxoJSON{hex.EncodeToString(txID), vout, entry.Output.Value, entry.Height, entry.Coinbase})
+		}
+
+		return UTXOs, nil
+	}
+
+	UTXOSet.forEach(func(txID []byte, vout int, entry UTXOEntry) {
+		if entry.Output.IsLockedWithKey(address.Hash) {
+			UTXOs = append(UTXOs, utxoJSON{hex.EncodeToString(txID), vout, entry.Output.Value, entry.Height, entry.Coinbase})
+		}
+	})
+
+	return UTXOs, nil
+}
+
+// /mempool: the transactions waiting for a block
+func restMempool(s *restServer, path string) (interface{}, error) {
+	result := mempoolJSON{Transactions: []txJSON{}}
+	for _, tx := range mempool {
+		result.Size++
+		result.Bytes += len(tx.Serialize())
+		result.Transactions = append(result.Transactions, newTxJSON(&tx))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestREST(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet.Address())
+	UTXOSet{bc}.Reindex()
+	block := bc.MineBlock([]*Transaction{NewCoinbaseTX(wallet.Address(), "")})
+	UTXOSet{bc}.Update(block)
+
+	bc.events = newEventFeed()
+	mux := http.NewServeMux()
+	handleREST(mux, bc)
+	get := func(path string, result interface{}) int {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, "*", recorder.Header().Get("Access-Control-Allow-Origin"))
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), result))
+
+		return recorder.Code
+	}
+
+	var blockResult blockJSON
+	assert.Equal(t, http.StatusOK, get("/block/"+hex.EncodeToString(block.Hash), &blockResult))
+	assert.Equal(t, 1, blockResult.Height)
+	assert.Equal(t, http.StatusOK, get("/block-height/1", &blockResult))
+	assert.Equal(t, hex.EncodeToString(block.Hash), blockResult.Hash)
+
+	var txResult txJSON
+	assert.Equal(t, http.StatusOK, get("/tx/"+hex.EncodeToString(block.Transactions[0].ID), &txResult))
+	assert.Equal(t, hex.EncodeToString(block.Hash), txResult.BlockHash)
+
+	var UTXOs []utxoJSON
+	assert.Equal(t, http.StatusOK, get("/address/"+wallet.Address().String()+"/utxos", &UTXOs))
+	assert.Equal(t, 2, len(UTXOs))
+	assert.Contains(t, UTXOs, utxoJSON{hex.EncodeToString(block.Transactions[0].ID), 0, subsidy, 1, true})
+
+	// The address index gives the same outputs
+	AddressIndex{bc}.Reindex()
+	var indexed []utxoJSON
+	assert.Equal(t, http.StatusOK, get("/address/"+wallet.Address().String()+"/utxos", &indexed))
+	assert.ElementsMatch(t, UTXOs, indexed)
+
+	var mempoolResult mempoolJSON
+	assert.Equal(t, http.StatusOK, get("/mempool", &mempoolResult))
+	assert.Equal(t, 0, mempoolResult.Size)
+
+	var errorResult map[string]string
+	assert.Equal(t, http.StatusBadRequest, get("/block/xyz", &errorResult))
+	assert.Equal(t, http.StatusBadRequest, get("/block-height/-1", &errorResult))
+	assert.Equal(t, http.StatusNotFound, get("/block-height/5", &errorResult))
+	assert.Equal(t, http.StatusNotFound, get("/tx/"+strings.Repeat("00", 32), &errorResult))
+	assert.NotEmpty(t, errorResult["error"])
+}
+
+func TestEventFeed(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet.Address())
+	genesis := bc.tip
+
+	bc.events = newEventFeed()
+	server := httptest.NewServer(bc.events)
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	assert.Nil(t, err)
+	defer conn.Close()
+	next := func() chainEvent {
+		var event chainEvent
+		assert.Nil(t, conn.ReadJSON(&event))
+		return event
+	}
+
+	tx := NewCoinbaseTX(wallet.Address(), "mempool")
+	acceptTransaction(bc, tx, "")
+	delete(mempool, hex.EncodeToString(tx.ID))
+	event := next()
+	assert.Equal(t, eventMempool, event.Type)
+	assert.Equal(t, hex.EncodeToString(tx.ID), event.Tx.TxID)
+
+	mined := bc.MineBlock([]*Transaction{NewCoinbaseTX(wallet.Address(), "")})
+	event = next()
+	assert.Equal(t, eventTip, event.Type)
+	assert.Equal(t, hex.EncodeToString(mined.Hash), event.Block.Hash)
+
+	// A longer branch from the genesis block takes over
+	side := NewBlock([]*Transaction{NewCoinbaseTX(wallet.Address(), "side")}, genesis, 1)
+	bc.AddBlock(side)
+	sideTip := NewBlock([]*Transaction{NewCoinbaseTX(wallet.Address(), "")}, side.Hash, 2)
+	bc.AddBlock(sideTip)
+
+	event = next()
+	assert.Equal(t, eventReorg, event.Type)
+	assert.Equal(t, []string{hex.EncodeToString(mined.Hash)}, event.Disconnected)
+	assert.Equal(t, []string{hex.EncodeToString(side.Hash), hex.EncodeToString(sideTip.Hash)}, event.Connected)
+	event = next()
+	assert.Equal(t, eventTip, event.Type)
+	assert.Equal(t, hex.EncodeToString(sideTip.Hash), event.Block.Hash)
+}
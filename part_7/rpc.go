@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// rpcPortOffset is added to the port of a node to get the port of its RPC server
+const rpcPortOffset = 10000
+
+// rpcCookieFile holds the credentials of the RPC server while the node runs, only its owner can read it
+const rpcCookieFile = "rpc_%s.cookie"
+const rpcCookieUser = "__cookie__"
+
+// JSON-RPC error codes, rpcMiscError is for errors of the methods themselves
+const (
+	rpcParseError     = -32700
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcMiscError      = -1
+)
+
+// rpcRequest is a JSON-RPC call, params are positional
+type rpcRequest struct {
+	JSONRPC string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+// rpcResponse carries either the result or the error of a call
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result"`
+	Error   *rpcError       `json:"error"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// rpcServer answers JSON-RPC calls for a running node, authenticated with the cookie
+type rpcServer struct {
+	bc     *Blockchain
+	nodeID string
+	cookie string
+}
+
+// rpcHandler runs a method with the params of a call and returns its result
+type rpcHandler func(s *rpcServer, params []json.RawMessage) (interface{}, error)
+
+// rpcAddress returns the address the RPC server of node nodeID listens on, only local connections reach it
+func rpcAddress(nodeID string) string {
+	port, err := strconv.Atoi(nodeID)
+	if err != nil {
+		log.Panicf("ERROR: NODE_ID must be a port number, got %q", nodeID)
+	}
+
+	return fmt.Sprintf("localhost:%d", port+rpcPortOffset)
+}
+
+func rpcCookiePath(nodeID string) string {
+	return fmt.Sprintf(rpcCookieFile, nodeID)
+}
+
+// startRPCServer writes a new cookie and serves JSON-RPC calls in the background. The REST API and the WebSocket
+// feed are served with them, unless restAddress gives them a listener of their own
+func startRPCServer(nodeID, restAddress string, bc *Blockchain) {
+	ln, err := net.Listen(protocol, rpcAddress(nodeID))
+	if err != nil {
+		log.Panic(err)
+	}
+
+	password := make([]byte, 32)
+	_, err = rand.Read(password)
+	if err != nil {
+		log.Panic(err)
+	}
+	s := &rpcServer{bc, nodeID, rpcCookieUser + ":" + hex.EncodeToString(password)}
+
+	err = ioutil.WriteFile(rpcCookiePath(nodeID), []byte(s.cookie), 0600)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", s)
+	if restAddress == "" {
+		handleREST(mux, bc)
+	} else {
+		startRESTServer(restAddress, bc)
+	}
+
+	fmt.Printf("RPC server is listening on %s\n", ln.Addr())
+	go http.Serve(ln, mux)
+}
+
+// stopRPCServer removes the cookie, so the CLI stops calling the node
+func stopRPCServer(nodeID string) {
+	os.Remove(rpcCookiePath(nodeID))
+}
+
+func (s *rpcServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, password, ok := r.BasicAuth()
+	if !ok || subtle.ConstantTimeCompare([]byte(user+":"+password), []byte(s.cookie)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="jsonrpc"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "JSON-RPC calls are POST requests", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request rpcRequest
+	response := rpcResponse{JSONRPC: "2.0"}
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		response.Error = &rpcError{rpcParseError, err.Error()}
+	} else {
+		response.ID = request.ID
+		response.Result, response.Error = s.call(request.Method, request.Params)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		fmt.Printf("Can't write the RPC response: %s\n", err)
+	}
+}
+
+// call runs a method, the panics the rest of the node reports errors with become RPC errors
+func (s *rpcServer) call(method string, params []json.RawMessage) (result interface{}, rpcErr *rpcError) {
+	handler, ok := rpcHandlers[method]
+	if !ok {
+		return nil, &rpcError{rpcMethodNotFound, fmt.Sprintf("Method %q not found", method)}
+	}
+
+	// Calls come in on their own goroutines, like the messages of other nodes
+	nodeLock.Lock()
+	defer nodeLock.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			result, rpcErr = nil, &rpcError{rpcMiscError, strings.TrimPrefix(fmt.Sprint(r), "ERROR: ")}
+		}
+	}()
+
+	result, err := handler(s, params)
+	if err != nil {
+		if e, ok := err.(*rpcError); ok {
+			return nil, e
+		}
+
+		return nil, &rpcError{rpcMiscError, err.Error()}
+	}
+
+	return result, nil
+}
+
+// parseParams decodes positional params into targets, the first required of them must be given.
+// Targets of params left out keep their values
+func parseParams(params []json.RawMessage, required int, targets ...interface{}) error {
+	if len(params) < required || len(params) > len(targets) {
+		return &rpcError{rpcInvalidParams, fmt.Sprintf("Expected %d to %d params, got %d", required, len(targets), len(params))}
+	}
+
+	for i, param := range params {
+		err := json.Unmarshal(param, targets[i])
+		if err != nil {
+			return &rpcError{rpcInvalidParams, fmt.Sprintf("Param %d is invalid: %s", i+1, err)}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// callNode calls method on the RPC server of the running node nodeID and decodes its result into result.
+// It returns false when the node isn't running, errors of the call panic like the other CLI errors
+func callNode(nodeID, method string, result interface{}, params ...interface{}) bool {
+	cookie, err := ioutil.ReadFile(rpcCookiePath(nodeID))
+	if err != nil {
+		return false
+	}
+	user, password, _ := strings.Cut(string(cookie), ":")
+
+	request := rpcRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: method}
+	for _, param := range params {
+		encoded, err := json.Marshal(param)
+		if err != nil {
+			log.Panic(err)
+		}
+		request.Params = append(request.Params, encoded)
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	httpRequest, err := http.NewRequest(http.MethodPost, "http://"+rpcAddress(nodeID)+"/", bytes.NewReader(body))
+	if err != nil {
+		log.Panic(err)
+	}
+	httpRequest.SetBasicAuth(user, password)
+	httpRequest.Header.Set("Content-Type", "application/json")
+
+	httpResponse, err := http.DefaultClient.Do(httpRequest)
+	if err != nil {
+		// The cookie was left behind by a node that didn't stop cleanly
+		return false
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode == http.StatusUnauthorized {
+		log.Panicf("ERROR: Node %s rejected the RPC cookie", nodeID)
+	}
+
+	var response struct {
+		Result json.RawMessage
+		Error  *rpcError
+	}
+	err = json.NewDecoder(httpResponse.Body).Decode(&response)
+	if err != nil {
+		log.Panic(err)
+	}
+	if response.Error != nil {
+		log.Panicf("ERROR: %s", response.Error.Message)
+	}
+
+	if result != nil {
+		err = json.Unmarshal(response.Result, result)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	return true
+}
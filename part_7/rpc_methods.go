@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// rpcHandlers are the methods of the RPC server by name
+var rpcHandlers = map[string]rpcHandler{
+	"getbalance":         rpcGetBalance,
+	"getblock":           rpcGetBlock,
+	"getblockchaininfo":  rpcGetBlockchainInfo,
+	"getblockhash":       rpcGetBlockHash,
+	"getmempoolinfo":     rpcGetMempoolInfo,
+	"getnewaddress":      rpcGetNewAddress,
+	"getpeerinfo":        rpcGetPeerInfo,
+	"getrawtransaction":  rpcGetRawTransaction,
+	"send":               rpcSend,
+	"sendrawtransaction": rpcSendRawTransaction,
+}
+
+// blockchainInfo is the result of getblockchaininfo
+type blockchainInfo struct {
+	Blocks        int    `json:"blocks"`
+	BestBlockHash string `json:"bestblockhash"`
+	Pruned        bool   `json:"pruned"`
+	PruneHeight   int    `json:"pruneheight,omitempty"`
+	// SnapshotPending is set while the blocks below the UTXO snapshot the node started from aren't checked
+	SnapshotPending bool `json:"snapshotpending"`
+}
+
+// blockJSON is a block with the IDs of its transactions
+type blockJSON struct {
+	Hash          string   `json:"hash"`
+	Height        int      `json:"height"`
+	PrevBlockHash string   `json:"previousblockhash,omitempty"`
+	MerkleRoot    string   `json:"merkleroot"`
+	Time          int64    `json:"time"`
+	Nonce         int      `json:"nonce"`
+	Transactions  []string `json:"tx"`
+}
+
+// txJSON is a transaction, BlockHash is empty while it's in the mempool
+type txJSON struct {
+	TxID      string         `json:"txid"`
+	Hex       string         `json:"hex"`
+	LockTime  int64          `json:"locktime"`
+	Vin       []txInputJSON  `json:"vin"`
+	Vout      []txOutputJSON `json:"vout"`
+	BlockHash string         `json:"blockhash,omitempty"`
+}
+
+type txInputJSON struct {
+	TxID     string `json:"txid,omitempty"`
+	Vout     int    `json:"vout"`
+	Sequence uint32 `json:"sequence"`
+	Coinbase bool   `json:"coinbase,omitempty"`
+}
+
+// txOutputJSON has the address of spendable outputs and the data of unspendable ones
+type txOutputJSON struct {
+	N       int    `json:"n"`
+	Value   int    `json:"value"`
+	Address string `json:"address,omitempty"`
+	Data    string `json:"data,omitempty"`
+}
+
+// mempoolInfo is the result of getmempoolinfo
+type mempoolInfo struct {
+	Size  int `json:"size"`
+	Bytes int `json:"bytes"`
+}
+
+// peerInfo is an entry of the result of getpeerinfo
+type peerInfo struct {
+	Addr string `json:"addr"`
+}
+
+func newBlockJSON(block *Block) blockJSON {
+	result := blockJSON{
+		Hash:       hex.EncodeToString(block.Hash),
+		Height:     block.Height,
+		MerkleRoot: hex.EncodeToString(block.HashTransactions()),
+		Time:       block.Timestamp,
+		Nonce:      block.Nonce,
+	}
+	if len(block.PrevBlockHash) > 0 {
+		result.PrevBlockHash = hex.EncodeToString(block.PrevBlockHash)
+	}
+	for _, tx := range block.Transactions {
+		result.Transactions = append(result.Transactions, hex.EncodeToString(tx.ID))
+	}
+
+	return result
+}
+
+func newTxJSON(tx *Transaction) txJSON {
+	result := txJSON{
+		TxID:     hex.EncodeToString(tx.ID),
+		Hex:      hex.EncodeToString(tx.Serialize()),
+		LockTime: tx.LockTime,
+	}
+	for _, vin := range tx.Vin {
+		if tx.IsCoinbase() {
+			result.Vin = append(result.Vin, txInputJSON{Vout: vin.Vout, Sequence: vin.Sequence, Coinbase: true})
+			continue
+		}
+		result.Vin = append(result.Vin, txInputJSON{TxID: hex.EncodeToString(vin.Txid), Vout: vin.Vout, Sequence: vin.Sequence})
+	}
+	for i, out := range tx.Vout {
+		output := txOutputJSON{N: i, Value: out.Value}
+		if out.IsUnspendable() {
+			output.Data = hex.EncodeToString(out.Data)
+		} else {
+			output.Address = out.Address().String()
+		}
+		result.Vout = append(result.Vout, output)
+	}
+
+	return result
+}
+
+// decodeHashParam decodes a hex-encoded block hash or transaction ID
+func decodeHashParam(param string) ([]byte, error) {
+	hash, err := hex.DecodeString(param)
+	if err != nil || len(hash) != 32 {
+		return nil, &rpcError{rpcInvalidParams, fmt.Sprintf("%q is not a hex-encoded hash", param)}
+	}
+
+	return hash, nil
+}
+
+// findTransaction looks for a transaction in the mempool and then in the chain,
+// the block is nil for transactions of the mempool
+func findTransaction(bc *Blockchain, ID []byte) (*Transaction, *Block, error) {
+	if tx, ok := mempool[hex.EncodeToString(ID)]; ok {
+		return &tx, nil, nil
+	}
+
+	block, err := bc.FindTransactionBlock(ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, tx := range block.Transactions {
+		if bytes.Equal(tx.ID, ID) {
+			return tx, block, nil
+		}
+	}
+
+	return nil, nil, errors.New("Transaction is not found")
+}
+
+// submitTransaction puts a transaction made on this node into its mempool and sends it to the central node,
+// which relays it to the others
+func submitTransaction(bc *Blockchain, tx *Transaction) error {
+	if !bc.VerifyTransaction(tx) {
+		return errors.New("Transaction is not fully signed")
+	}
+
+	err := acceptTransaction(bc, tx, nodeAddress)
+	if err != nil {
+		return err
+	}
+
+	if nodeAddress != knownNodes[0] {
+		sendTx(knownNodes[0], tx)
+	}
+
+	return nil
+}
+
+// getbalance [address]: the balance of address, or of all wallet addresses without it
+func rpcGetBalance(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	address := ""
+	err := parseParams(params, 0, &address)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := []string{address}
+	if address == "" {
+		addresses = nodeWallets.GetAddresses()
+	}
+
+	balance := 0
+	for _, address := range addresses {
+		decoded, err := DecodeAddress(address)
+		if err != nil {
+			return nil, err
+		}
+		balance += addressBalance(s.bc, decoded)
+	}
+
+	return balance, nil
+}
+
+// getblock hash
+func rpcGetBlock(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	var hashParam string
+	err := parseParams(params, 1, &hashParam)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := decodeHashParam(hashParam)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := s.bc.GetBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBlockJSON(&block), nil
+}
+
+// getblockchaininfo
+func rpcGetBlockchainInfo(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	err := parseParams(params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	pruneHeight := s.bc.PruneHeight()
+
+	return blockchainInfo{s.bc.GetBestHeight(), hex.EncodeToString(s.bc.tip), pruneHeight > 0, pruneHeight, s.bc.SnapshotPending()}, nil
+}
+
+// getblockhash height
+func rpcGetBlockHash(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	var height int
+	err := parseParams(params, 1, &height)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := HeightIndex{s.bc}.Hash(height)
+	if err != nil {
+		return nil, err
+	}
+
+	return hex.EncodeToString(hash), nil
+}
+
+// getmempoolinfo
+func rpcGetMempoolInfo(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	err := parseParams(params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	info := mempoolInfo{}
+	for _, tx := range mempool {
+		info.Size++
+		info.Bytes += len(tx.Serialize())
+	}
+
+	return info, nil
+}
+
+// getnewaddress [type]: a new address of the node's wallet, type is base58 or bech32
+func rpcGetNewAddress(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	addressType := "base58"
+	err := parseParams(params, 0, &addressType)
+	if err != nil {
+		return nil, err
+	}
+
+	encoding, err := ParseAddressEncoding(addressType)
+	if err != nil {
+		return nil, err
+	}
+
+	address := nodeWallets.CreateWallet(encoding)
+	nodeWallets.SaveToFile(s.nodeID)
+
+	return address, nil
+}
+
+// getpeerinfo
+func rpcGetPeerInfo(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	err := parseParams(params, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	peers := []peerInfo{}
+	for _, node := range knownNodes {
+		if node != nodeAddress {
+			peers = append(peers, peerInfo{node})
+		}
+	}
+
+	return peers, nil
+}
+
+// getrawtransaction txid [verbose]: the hex-encoded transaction, decoded when verbose is true
+func rpcGetRawTransaction(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	var txIDParam string
+	verbose := false
+	err := parseParams(params, 1, &txIDParam, &verbose)
+	if err != nil {
+		return nil, err
+	}
+	txID, err := decodeHashParam(txIDParam)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, block, err := findTransaction(s.bc, txID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !verbose {
+		return hex.EncodeToString(tx.Serialize()), nil
+	}
+
+	result := newTxJSON(tx)
+	if block != nil {
+		result.BlockHash = hex.EncodeToString(block.Hash)
+	}
+
+	return result, nil
+}
+
+// send recipients [from] [coinselection] [locktime] [sequence]: pays the recipients, a list of
+// {"address", "amount"} objects, from the comma separated from addresses or the whole wallet
+func rpcSend(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	var recipients []Recipient
+	from := ""
+	coinSelection := defaultCoinSelection
+	var lockTime int64
+	var sequence uint32
+	err := parseParams(params, 1, &recipients, &from, &coinSelection, &lockTime, &sequence)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateRecipients(recipients)
+	if err != nil {
+		return nil, err
+	}
+	selector, err := GetCoinSelector(coinSelection)
+	if err != nil {
+		return nil, err
+	}
+	// Outputs spent by the mempool are still in the UTXO set until the next block
+	selector = excludeOutputs(selector, mempoolSpentOutputs())
+	if nodeWallets.IsLocked() {
+		return nil, errors.New("Wallet is locked, unlock it with walletpassphrase")
+	}
+	if !lockTimeReached(lockTime, sequence, s.bc.GetBestHeight()+1, time.Now().Unix()) {
+		return nil, errors.New("Transaction is locked, use createrawtx and send it with sendrawtransaction once it is final")
+	}
+
+	senders := walletSenders(nodeWallets, from)
+	change := nodeWallets.Wallets[nodeWallets.CreateChangeWallet(senders[0].Encoding)].Address()
+
+	UTXOSet := UTXOSet{s.bc}
+	tx := NewUTXOTransaction(senders, recipients, change, selector, lockTime, sequence, &UTXOSet)
+
+	// Keep the change key before the transaction leaves this node
+	if len(tx.Vout) > len(recipients) {
+		nodeWallets.SaveToFile(s.nodeID)
+	}
+
+	err = submitTransaction(s.bc, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return hex.EncodeToString(tx.ID), nil
+}
+
+// sendrawtransaction hex: broadcasts a fully signed transaction, hex-encoded as getrawtransaction returns it
+func rpcSendRawTransaction(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	var txHex string
+	err := parseParams(params, 1, &txHex)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, &rpcError{rpcInvalidParams, "Transaction is not hex-encoded"}
+	}
+	tx := DeserializeTransaction(data)
+
+	err = submitTransaction(s.bc, &tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return hex.EncodeToString(tx.ID), nil
+}
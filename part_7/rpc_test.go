@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRPCServer(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet.Address())
+	UTXOSet{bc}.Reindex()
+	block := bc.MineBlock([]*Transaction{NewCoinbaseTX(wallet.Address(), "")})
+	UTXOSet{bc}.Update(block)
+
+	s := &rpcServer{bc, "3000", rpcCookieUser + ":secret"}
+	call := func(password, body string) (int, rpcResponse) {
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		request.SetBasicAuth(rpcCookieUser, password)
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, request)
+
+		var response rpcResponse
+		if recorder.Code == http.StatusOK {
+			assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+		}
+
+		return recorder.Code, response
+	}
+
+	code, _ := call("wrong", `{"method": "getblockchaininfo"}`)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	_, response := call("secret", `{"id": 7, "method": "getblockchaininfo"}`)
+	assert.Nil(t, response.Error)
+	assert.Equal(t, "7", string(response.ID))
+	info := response.Result.(map[string]interface{})
+	assert.Equal(t, float64(1), info["blocks"])
+	assert.Equal(t, hex.EncodeToString(block.Hash), info["bestblockhash"])
+
+	_, response = call("secret", `{"method": "getblockhash", "params": [1]}`)
+	assert.Equal(t, hex.EncodeToString(block.Hash), response.Result)
+
+	_, response = call("secret", `{"method": "getblock", "params": ["`+hex.EncodeToString(block.Hash)+`"]}`)
+	assert.Nil(t, response.Error)
+	assert.Equal(t, []interface{}{hex.EncodeToString(block.Transactions[0].ID)}, response.Result.(map[string]interface{})["tx"])
+
+	_, response = call("secret", `{"method": "getrawtransaction", "params": ["`+hex.EncodeToString(block.Transactions[0].ID)+`", true]}`)
+	assert.Nil(t, response.Error)
+	assert.Equal(t, hex.EncodeToString(block.Hash), response.Result.(map[string]interface{})["blockhash"])
+
+	_, response = call("secret", `{"method": "getbalance", "params": ["`+wallet.Address().String()+`"]}`)
+	assert.Equal(t, float64(2*subsidy), response.Result)
+
+	_, response = call("secret", `{"method": "getblockhash", "params": []}`)
+	assert.Equal(t, rpcInvalidParams, response.Error.Code)
+
+	_, response = call("secret", `{"method": "stop"}`)
+	assert.Equal(t, rpcMethodNotFound, response.Error.Code)
+
+	// Panics of the node are errors of the call
+	_, response = call("secret", `{"method": "sendrawtransaction", "params": ["00"]}`)
+	assert.Equal(t, rpcMiscError, response.Error.Code)
+	assert.Nil(t, response.Result)
+}
+
+func TestAcceptTransactionConflicts(t *testing.T) {
+	sender := NewWallet()
+	bc := newTestBlockchain(t, sender.Address())
+	UTXOSet := UTXOSet{bc}
+	UTXOSet.Reindex()
+	block := bc.MineBlock([]*Transaction{NewCoinbaseTX(sender.Address(), "")})
+	UTXOSet.Update(block)
+	t.Cleanup(func() { mempool = make(map[string]Transaction) })
+
+	selector, err := GetCoinSelector("largest")
+	assert.Nil(t, err)
+	recipients := []Recipient{{NewWallet().Address(), 4}}
+	first := NewUTXOTransaction([]*Wallet{sender}, recipients, sender.Address(), selector, 0, sequenceFinal, &UTXOSet)
+	assert.Nil(t, acceptTransaction(bc, first, ""))
+
+	again := *first
+	again.Vout = []TXOutput{*NewTXOutput(3, recipients[0].Address)}
+	again.ID = again.Hash()
+	assert.ErrorContains(t, acceptTransaction(bc, &again, ""), "mempool")
+	assert.Len(t, mempool, 1, "A transaction spending the same output is rejected")
+
+	// Sends pick outputs the mempool doesn't spend yet
+	second := NewUTXOTransaction([]*Wallet{sender}, recipients, sender.Address(), excludeOutputs(selector, mempoolSpentOutputs()), 0, sequenceFinal, &UTXOSet)
+	assert.NotEqual(t, first.Vin[0].Txid, second.Vin[0].Txid)
+	assert.Nil(t, acceptTransaction(bc, second, ""))
+}
@@ -9,6 +9,11 @@ import (
 	"io/ioutil"
 	"log"
 	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 )
 
 const protocol = "tcp"
@@ -18,11 +23,26 @@ const commandLength = 12
 var nodeAddress string
 var miningAddress string
 
+// 修剪模式下区块文件占用的空间上限（字节），为 0 时保留全部区块
+var pruneTarget int64
+
+// 同一时间只在后台验证一次 UTXO 快照
+var validatingSnapshot sync.Mutex
+
 // 对中心节点的地址进行硬编码：因为每个节点必须知道从何处开始初始化
 var knownNodes = []string{"localhost:3000"}
 var blocksInTransit = [][]byte{}
 var mempool = make(map[string]Transaction)
 
+// 节点持有的钱包，加密的钱包需要用 walletunlock 消息解锁后才能签名
+var nodeWallets *Wallets
+
+// 到时自动锁定钱包的计时器
+var walletLockTimer *time.Timer
+
+// 各个连接、RPC 调用和 REST 请求都在自己的 goroutine 里处理，内存池、钱包和区块链只能在持有 nodeLock 时读写
+var nodeLock sync.Mutex
+
 // 允许节点来互相发现彼此
 type addr struct {
 	AddrList []string
@@ -64,6 +84,14 @@ type verzion struct {
 	Version    int    // 仅有一个区块链版本,Version 并不会存储什么信息
 	BestHeight int    // 存储区块链中节点的高度
 	AddrFrom   string // 存储发送节点的地址
+	// PruneHeight 以下的区块已被修剪节点删除，不能再向它请求
+	PruneHeight int
+}
+
+// walletunlock 用于解锁节点持有的钱包，Timeout 秒后钱包会自动锁定
+type walletunlock struct {
+	Passphrase string
+	Timeout    int
 }
 
 // commandToBytes 创建一个 12 字节的缓冲区，并用命令名进行填充，将剩下的字节置为空
@@ -175,7 +203,7 @@ func sendTx(addr string, tnx *Transaction) {
 // 发送版本消息 -- 消息，在底层就是字节序列
 func sendVersion(addr string, bc *Blockchain) {
 	bestHeight := bc.GetBestHeight()
-	payload := gobEncode(verzion{nodeVersion, bestHeight, nodeAddress})
+	payload := gobEncode(verzion{nodeVersion, bestHeight, nodeAddress, bc.PruneHeight()})
 
 	//前 12 个字节指定了命令名（比如这里的 version），后面的字节会包含 gob 编码的消息结构
 	request := append(commandToBytes("version"), payload...)
@@ -183,6 +211,20 @@ func sendVersion(addr string, bc *Blockchain) {
 	sendData(addr, request)
 }
 
+// 钱包相关的消息只在本机发送，以免密码在网络上传输
+func sendWalletUnlock(addr, passphrase string, timeout int) {
+	payload := gobEncode(walletunlock{passphrase, timeout})
+	request := append(commandToBytes("walletunlock"), payload...)
+
+	sendData(addr, request)
+}
+
+func sendWalletLock(addr string) {
+	request := commandToBytes("walletlock")
+
+	sendData(addr, request)
+}
+
 func handleAddr(request []byte) {
 	var buff bytes.Buffer
 	var payload addr
@@ -215,6 +257,20 @@ func handleBlock(request []byte, bc *Blockchain) {
 
 	// 当接收到一个新块时，我们把它放到区块链里面。TODO 应该对块做校验
 	fmt.Println("Recevied a new block!")
+
+	// 锁定时间未到的交易不能被打包。只有接在当前链尾的块才能查到前序交易所在的块，从而检查相对锁定时间
+	for _, tx := range block.Transactions {
+		final := tx.IsFinal(block.Height, block.Timestamp)
+		if final && bytes.Compare(block.PrevBlockHash, bc.tip) == 0 {
+			final = bc.IsTransactionFinal(tx, block.Height, block.Timestamp)
+		}
+
+		if !final {
+			fmt.Printf("Block %x contains a non-final transaction %x, rejected\n", block.Hash, tx.ID)
+			return
+		}
+	}
+
 	bc.AddBlock(block)
 
 	fmt.Printf("Added block %x\n", block.Hash)
@@ -226,12 +282,89 @@ func handleBlock(request []byte, bc *Blockchain) {
 
 		blocksInTransit = blocksInTransit[1:]
 	} else {
-		// 当最后把所有块都下载完后，对 UTXO 集进行重新索引。
-		UTXOSet := UTXOSet{bc}
-		UTXOSet.Reindex()
+		// 当最后把所有块都下载完后，用新块更新 UTXO 集，并把缓存中的改动写入数据库
+		syncUTXOSet(bc)
+		UTXOSet{bc}.Flush()
+		syncIndexes(bc)
+		go validateSnapshot(bc)
+		pruneBlocks(bc)
+	}
+}
+
+// 从快照启动的节点下载完快照以下的区块后，在后台用这些区块重新计算 UTXO 集并与快照的哈希比较
+func validateSnapshot(bc *Blockchain) {
+	if !validatingSnapshot.TryLock() {
+		return
+	}
+	defer validatingSnapshot.Unlock()
+
+	done, err := bc.ValidateSnapshot()
+	if err != nil {
+		fmt.Println(err)
+	}
+	if !done {
+		return
+	}
+
+	// 快照以前的钱包交易记录这时才能补上
+	if nodeWallets != nil {
+		WalletHistory{bc, nodeWallets}.Rescan()
+	}
+	if err == nil {
+		fmt.Println("UTXO snapshot matches the blocks, the node has the whole chain now")
 	}
 }
 
+// 索引都跟上链尾以后，修剪节点删除最旧的区块文件，直到不超过 pruneTarget，最近的 minBlocksToKeep 个块总会保留
+func pruneBlocks(bc *Blockchain) {
+	// 快照还没有用历史区块验证之前不能修剪
+	if pruneTarget == 0 || bc.SnapshotPending() {
+		return
+	}
+
+	// 缓存中的 UTXO 集改动要先写入数据库，否则节点中断后需要重新连接的区块可能已经被删掉了
+	UTXOSet{bc}.Flush()
+
+	removed, err := bc.Prune(pruneTarget, minBlocksToKeep)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if removed > 0 {
+		fmt.Printf("Pruned %d block files, blocks below height %d are gone\n", removed, bc.PruneHeight())
+	}
+}
+
+// UTXO 集从它已同步到的块更新到链尾，链尾切换到其它分支时用撤销记录断开旧分支上的块。
+// 撤销记录只保留最近 minBlocksToKeep 个块，更深的分叉全节点重建 UTXO 集，修剪节点和从快照启动的节点没有全部旧区块，无法重建
+func syncUTXOSet(bc *Blockchain) {
+	UTXOSet := UTXOSet{bc}
+	err := UTXOSet.Sync()
+	if err == nil {
+		return
+	}
+
+	// 修剪节点没有重建 UTXO 集所需的区块，链尾已经切换，不能带着过时的 UTXO 集继续运行
+	if bc.PruneHeight() > 0 {
+		log.Panicf("ERROR: Can't update the UTXO set: %s", err)
+	}
+	fmt.Printf("Reindexing the UTXO set: %s\n", err)
+	UTXOSet.Reindex()
+}
+
+// 钱包交易记录和地址索引跟随新的链尾更新，链尾切换到其它分支时会撤销旧分支上的记录
+func syncIndexes(bc *Blockchain) {
+	addressIndex := AddressIndex{bc}
+	addressIndex.Sync()
+
+	if nodeWallets == nil {
+		return
+	}
+
+	history := WalletHistory{bc, nodeWallets}
+	history.Sync()
+}
+
 // 处理 Inv 消息
 func handleInv(request []byte, bc *Blockchain) {
 	var buff bytes.Buffer
@@ -248,20 +381,23 @@ func handleInv(request []byte, bc *Blockchain) {
 
 	// 如果收到块哈希，我们想要将它们保存在 blocksInTransit 变量来跟踪已下载的块。这能够让我们从不同的节点下载块。
 	if payload.Type == "block" {
-		blocksInTransit = payload.Items
+		// inv 里的区块从链尾往前排列，倒过来从最旧的开始下载，这样每个块到达时它的父块都已经在链上了。已有的块不再下载
+		blocksInTransit = [][]byte{}
+		for i := len(payload.Items) - 1; i >= 0; i-- {
+			if bc.needsBlock(payload.Items[i]) {
+				blocksInTransit = append(blocksInTransit, payload.Items[i])
+			}
+		}
+		if len(blocksInTransit) == 0 {
+			return
+		}
 
 		// 在将块置于传送状态时，我们给 inv 消息的发送者发送 getdata 命令。
-		blockHash := payload.Items[0]
+		blockHash := blocksInTransit[0]
 		sendGetData(payload.AddrFrom, "block", blockHash)
 
-		newInTransit := [][]byte{}
-		for _, b := range blocksInTransit {
-			if bytes.Compare(b, blockHash) != 0 {
-				newInTransit = append(newInTransit, b)
-			}
-		}
 		// 更新 blocksInTransit
-		blocksInTransit = newInTransit
+		blocksInTransit = blocksInTransit[1:]
 	}
 
 	if payload.Type == "tx" {
@@ -336,13 +472,51 @@ func handleTx(request []byte, bc *Blockchain) {
 
 	txData := payload.Transaction
 	tx := DeserializeTransaction(txData)
-	mempool[hex.EncodeToString(tx.ID)] = tx
+
+	err = acceptTransaction(bc, &tx, payload.AddFrom)
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// mempoolSpentOutputs 返回内存池中的交易花费的输出，键是输出在 chainstate 中的键
+func mempoolSpentOutputs() map[string]bool {
+	spent := make(map[string]bool)
+	for _, tx := range mempool {
+		if tx.IsCoinbase() {
+			continue
+		}
+		for _, vin := range tx.Vin {
+			spent[string(outpointKey(vin.Txid, vin.Vout))] = true
+		}
+	}
+
+	return spent
+}
+
+// 交易放入内存池。中心节点把交易转发给除 from 以外的节点，矿工节点在内存池中有足够的交易时开始挖矿
+func acceptTransaction(bc *Blockchain, tx *Transaction, from string) error {
+	// 锁定时间未到的交易不会进入内存池
+	if !bc.IsTransactionFinal(tx, bc.GetBestHeight()+1, time.Now().Unix()) {
+		return fmt.Errorf("Transaction %x is not final yet, rejected", tx.ID)
+	}
+	// 和内存池中的交易花费同一个输出的交易会被拒绝，否则矿工会把两者放进同一个块
+	if _, ok := mempool[hex.EncodeToString(tx.ID)]; !ok && !tx.IsCoinbase() {
+		spent := mempoolSpentOutputs()
+		for _, vin := range tx.Vin {
+			if spent[string(outpointKey(vin.Txid, vin.Vout))] {
+				return fmt.Errorf("Transaction %x spends output %d of %x, which a transaction in the mempool spends, rejected", tx.ID, vin.Vout, vin.Txid)
+			}
+		}
+	}
+	mempool[hex.EncodeToString(tx.ID)] = *tx
+	bc.publishTransaction(tx)
 
 	// 检查当前节点是否是中心节点。
 	// 在我们的实现中，中心节点并不会挖矿。它只会将新的交易推送给网络中的其他节点。
 	if nodeAddress == knownNodes[0] {
 		for _, node := range knownNodes {
-			if node != nodeAddress && node != payload.AddFrom {
+			if node != nodeAddress && node != from {
 				sendInv(node, "tx", [][]byte{tx.ID})
 			}
 		}
@@ -356,25 +530,30 @@ func handleTx(request []byte, bc *Blockchain) {
 			// 首先，内存池中所有交易都是要通过验证的
 			for id := range mempool {
 				tx := mempool[id]
-				// 	// 无效的交易会被忽略，
-				if bc.VerifyTransaction(&tx) {
+				// 	// 无效的交易和锁定时间未到的交易会被忽略，
+				if bc.VerifyTransaction(&tx) && bc.IsTransactionFinal(&tx, bc.GetBestHeight()+1, time.Now().Unix()) {
 					txs = append(txs, &tx)
 				}
 			}
 			//	如果没有有效交易，则挖矿中断
 			if len(txs) == 0 {
 				fmt.Println("All transactions are invalid! Waiting for new ones...")
-				return
+				return nil
 			}
 
 			// 验证后的交易被放到一个块里，同时还有附带奖励的 coinbase 交易
-			cbTx := NewCoinbaseTX(miningAddress, "")
+			minerAddress, err := DecodeAddress(miningAddress)
+			if err != nil {
+				log.Panic(err)
+			}
+			cbTx := NewCoinbaseTX(minerAddress, "")
 			txs = append(txs, cbTx)
 
-			// 当块被挖出来以后，UTXO 集会被重新索引
+			// 当块被挖出来以后，用新块更新 UTXO 集
 			newBlock := bc.MineBlock(txs)
-			UTXOSet := UTXOSet{bc}
-			UTXOSet.Reindex()
+			syncUTXOSet(bc)
+			syncIndexes(bc)
+			pruneBlocks(bc)
 
 			fmt.Println("New block is mined!")
 
@@ -397,6 +576,56 @@ func handleTx(request []byte, bc *Blockchain) {
 			}
 		}
 	}
+
+	return nil
+}
+
+// 处理钱包解锁消息
+func handleWalletUnlock(request []byte) {
+	var buff bytes.Buffer
+	var payload walletunlock
+
+	buff.Write(request[commandLength:])
+	dec := gob.NewDecoder(&buff)
+	err := dec.Decode(&payload)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = nodeWallets.Unlock(payload.Passphrase)
+	if err != nil {
+		fmt.Printf("Can't unlock the wallet: %s\n", err)
+		return
+	}
+
+	// 计时器在自己的 goroutine 里运行，锁定钱包前同样要持有 nodeLock
+	if walletLockTimer != nil {
+		walletLockTimer.Stop()
+		walletLockTimer = nil
+	}
+	if payload.Timeout <= 0 {
+		fmt.Println("Wallet is unlocked")
+		return
+	}
+	walletLockTimer = time.AfterFunc(time.Duration(payload.Timeout)*time.Second, func() {
+		nodeLock.Lock()
+		defer nodeLock.Unlock()
+
+		nodeWallets.Lock()
+	})
+
+	fmt.Printf("Wallet is unlocked for %d seconds\n", payload.Timeout)
+}
+
+// 处理钱包锁定消息
+func handleWalletLock() {
+	if walletLockTimer != nil {
+		walletLockTimer.Stop()
+		walletLockTimer = nil
+	}
+	nodeWallets.Lock()
+
+	fmt.Println("Wallet is locked")
 }
 
 // 处理版本消息连接
@@ -417,8 +646,15 @@ func handleVersion(request []byte, bc *Blockchain) {
 
 	// 然后节点将从消息中提取的 BestHeight 与自身进行比较。
 	// 如果自身节点的区块链更长，它会回复 version 消息；否则，它会发送 getblocks 消息。
-	if myBestHeight < foreignerBestHeight {
-		sendGetBlocks(payload.AddrFrom)
+	// 从快照启动的节点即使高度不落后，也要从没有修剪的节点下载快照以下的区块
+	needsHistory := bc.SnapshotPending() && payload.PruneHeight == 0
+	if myBestHeight < foreignerBestHeight || needsHistory {
+		// 修剪节点不再提供 PruneHeight 以下的区块，缺少这些区块的节点无法从它同步
+		if myBestHeight+1 < payload.PruneHeight {
+			fmt.Printf("Node %s is pruned below height %d, can't sync from it\n", payload.AddrFrom, payload.PruneHeight)
+		} else {
+			sendGetBlocks(payload.AddrFrom)
+		}
 	} else if myBestHeight > foreignerBestHeight {
 		sendVersion(payload.AddrFrom, bc)
 	}
@@ -439,6 +675,9 @@ func handleConnection(conn net.Conn, bc *Blockchain) {
 	command := bytesToCommand(request[:commandLength])
 	fmt.Printf("Received %s command\n", command)
 
+	nodeLock.Lock()
+	defer nodeLock.Unlock()
+
 	// 选择正确的处理器处理命令主体
 	switch command {
 	case "addr":
@@ -455,6 +694,17 @@ func handleConnection(conn net.Conn, bc *Blockchain) {
 		handleTx(request, bc)
 	case "version":
 		handleVersion(request, bc)
+	case "walletunlock", "walletlock":
+		// 只接受来自本机的钱包消息
+		if !isLocalConnection(conn) {
+			fmt.Println("Wallet commands are only accepted from localhost")
+			break
+		}
+		if command == "walletunlock" {
+			handleWalletUnlock(request)
+		} else {
+			handleWalletLock()
+		}
 	default:
 		fmt.Println("Unknown command!")
 	}
@@ -463,10 +713,11 @@ func handleConnection(conn net.Conn, bc *Blockchain) {
 }
 
 // StartServer 启动一个新节点
-func StartServer(nodeID, minerAddress string) {
+func StartServer(nodeID, minerAddress, restAddress string, prune, cacheSize int64) {
 	nodeAddress = fmt.Sprintf("localhost:%s", nodeID)
 	// minerAddress 参数指定了接收挖矿奖励的地址
 	miningAddress = minerAddress
+	pruneTarget = prune
 	ln, err := net.Listen(protocol, nodeAddress)
 	if err != nil {
 		log.Panic(err)
@@ -474,6 +725,25 @@ func StartServer(nodeID, minerAddress string) {
 	defer ln.Close()
 
 	bc := NewBlockchain(nodeID)
+	nodeWallets, _ = NewWallets(nodeID)
+
+	// 节点在内存中缓存 UTXO 集，超出 cacheSize 时才批量写入数据库。
+	// 上次没有写入的改动从数据库记录的块往前重新连接区块补上
+	bc.utxoCache = newUTXOCache(cacheSize)
+	syncUTXOSet(bc)
+	stopOnSignal(nodeID, bc)
+
+	// 命令行和其它程序通过 JSON-RPC 控制运行中的节点，不用再打开被节点锁住的数据库。
+	// 同一个端口上还有只读的 REST 接口和推送新区块、新交易的 WebSocket，供区块浏览器使用，
+	// 设置了 restAddress 时它们改为在这个地址上单独监听
+	bc.events = newEventFeed()
+	startRPCServer(nodeID, restAddress, bc)
+
+	// 修剪节点用更小的区块文件，这样删掉整个文件就能腾出空间
+	if pruneTarget > 0 {
+		bc.blocks.maxSize = pruneTarget / 4
+		pruneBlocks(bc)
+	}
 
 	// 如果当前节点不是中心节点，它必须向中心节点发送 version 消息来查询是否自己的区块链已过时
 	if nodeAddress != knownNodes[0] {
@@ -491,6 +761,23 @@ func StartServer(nodeID, minerAddress string) {
 	}
 }
 
+// 节点被中断时把缓存的 UTXO 集写入数据库再退出，这样命令行读到的 UTXO 集和链尾一致。
+// RPC 的 cookie 也被删除，命令行就不再调用这个节点
+func stopOnSignal(nodeID string, bc *Blockchain) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		// 等正在处理的消息和调用结束，之后不再处理新的
+		nodeLock.Lock()
+		stopRPCServer(nodeID)
+		UTXOSet{bc}.Flush()
+		bc.db.Close()
+		os.Exit(0)
+	}()
+}
+
 func gobEncode(data interface{}) []byte {
 	var buff bytes.Buffer
 
@@ -503,6 +790,12 @@ func gobEncode(data interface{}) []byte {
 	return buff.Bytes()
 }
 
+func isLocalConnection(conn net.Conn) bool {
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+
+	return ok && addr.IP.IsLoopback()
+}
+
 func nodeIsKnown(addr string) bool {
 	for _, node := range knownNodes {
 		if node == addr {
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Storage backends, picked with the STORE env. var. Tests keep their chains in a memoryStore instead
+const (
+	boltBackend    = "bolt"
+	levelDBBackend = "leveldb"
+)
+
+// activeBackend is the backend databases of the node are created and opened with
+var activeBackend = boltBackend
+
+// storeOpenTimeout is how long opening a database waits for another process to release it
+const storeOpenTimeout = time.Second
+
+var (
+	errBucketExists   = errors.New("Bucket already exists")
+	errBucketNotFound = errors.New("Bucket not found")
+)
+
+// Store is the key-value database the blocks, chainstate and indexes of a Blockchain are kept in.
+// Keys are grouped in buckets, all reads and writes happen in a transaction
+type Store interface {
+	// View runs fn in a read-only transaction
+	View(fn func(tx StoreTx) error) error
+	// Update runs fn in a read-write transaction, committed when fn returns nil and rolled back otherwise
+	Update(fn func(tx StoreTx) error) error
+	Close() error
+}
+
+// StoreTx is a transaction of a Store
+type StoreTx interface {
+	// Bucket returns the bucket called name, nil when it doesn't exist
+	Bucket(name []byte) StoreBucket
+	CreateBucket(name []byte) (StoreBucket, error)
+	CreateBucketIfNotExists(name []byte) (StoreBucket, error)
+	DeleteBucket(name []byte) error
+}
+
+// StoreBucket is a set of keys of a StoreTx. Values are only valid until the transaction ends
+type StoreBucket interface {
+	Get(key []byte) []byte
+	Put(key, value []byte) error
+	Delete(key []byte) error
+	ForEach(fn func(k, v []byte) error) error
+	Cursor() StoreCursor
+}
+
+// StoreCursor walks the keys of a bucket in byte order, a nil key means it went past either end
+type StoreCursor interface {
+	First() ([]byte, []byte)
+	Last() ([]byte, []byte)
+	// Seek moves to the first key at or after seek
+	Seek(seek []byte) ([]byte, []byte)
+	Next() ([]byte, []byte)
+	Prev() ([]byte, []byte)
+}
+
+// ParseBackend returns the storage backend called name, bolt when name is empty
+func ParseBackend(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case "", boltBackend:
+		return boltBackend, nil
+	case levelDBBackend:
+		return levelDBBackend, nil
+	}
+
+	return "", fmt.Errorf("Unknown storage backend %q, use bolt or leveldb", name)
+}
+
+// storePath returns where the database of a node is kept with the active backend
+func storePath(nodeID string) string {
+	path := fmt.Sprintf(dbFile, nodeID)
+	if activeBackend == levelDBBackend {
+		return strings.TrimSuffix(path, ".db") + ".leveldb"
+	}
+
+	return path
+}
+
+// openStore opens or creates the database of a node with the active backend
+func openStore(nodeID string) (Store, error) {
+	var store Store
+	var err error
+
+	switch activeBackend {
+	case levelDBBackend:
+		store, err = openLevelDBStore(storePath(nodeID))
+	default:
+		store, err = openBoltStore(storePath(nodeID))
+	}
+
+	// A running node holds its database for as long as it runs
+	if _, statErr := os.Stat(rpcCookiePath(nodeID)); err != nil && statErr == nil {
+		return nil, fmt.Errorf("Node %s is running and holds the database, stop it first or use a command it answers over RPC: %s", nodeID, err)
+	}
+
+	return store, err
+}
+
+// storeExists checks whether the node has a database for the active backend
+func storeExists(nodeID string) bool {
+	if _, err := os.Stat(storePath(nodeID)); os.IsNotExist(err) {
+		return false
+	}
+
+	return true
+}
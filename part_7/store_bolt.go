@@ -0,0 +1,102 @@
+package main
+
+import (
+	"github.com/boltdb/bolt"
+)
+
+// boltStore keeps a Store in a BoltDB file, its buckets are bolt buckets
+type boltStore struct {
+	db *bolt.DB
+}
+
+func openBoltStore(path string) (*boltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: storeOpenTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	return &boltStore{db}, nil
+}
+
+func (s *boltStore) View(fn func(tx StoreTx) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return fn(boltTx{tx})
+	})
+}
+
+func (s *boltStore) Update(fn func(tx StoreTx) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return fn(boltTx{tx})
+	})
+}
+
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
+
+type boltTx struct {
+	tx *bolt.Tx
+}
+
+func (t boltTx) Bucket(name []byte) StoreBucket {
+	b := t.tx.Bucket(name)
+	if b == nil {
+		return nil
+	}
+
+	return boltBucket{b}
+}
+
+func (t boltTx) CreateBucket(name []byte) (StoreBucket, error) {
+	b, err := t.tx.CreateBucket(name)
+	if err == bolt.ErrBucketExists {
+		return nil, errBucketExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return boltBucket{b}, nil
+}
+
+func (t boltTx) CreateBucketIfNotExists(name []byte) (StoreBucket, error) {
+	b, err := t.tx.CreateBucketIfNotExists(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return boltBucket{b}, nil
+}
+
+func (t boltTx) DeleteBucket(name []byte) error {
+	err := t.tx.DeleteBucket(name)
+	if err == bolt.ErrBucketNotFound {
+		return errBucketNotFound
+	}
+
+	return err
+}
+
+type boltBucket struct {
+	b *bolt.Bucket
+}
+
+func (b boltBucket) Get(key []byte) []byte {
+	return b.b.Get(key)
+}
+
+func (b boltBucket) Put(key, value []byte) error {
+	return b.b.Put(key, value)
+}
+
+func (b boltBucket) Delete(key []byte) error {
+	return b.b.Delete(key)
+}
+
+func (b boltBucket) ForEach(fn func(k, v []byte) error) error {
+	return b.b.ForEach(fn)
+}
+
+func (b boltBucket) Cursor() StoreCursor {
+	return b.b.Cursor()
+}
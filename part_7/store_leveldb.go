@@ -0,0 +1,228 @@
+package main
+
+import (
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Key prefixes of a LevelDB store: buckets are a marker key and a prefix in front of their keys
+const (
+	levelDBBucketMarker = 0x00
+	levelDBBucketKey    = 0x01
+)
+
+// levelDBStore keeps a Store in a LevelDB directory, an alternative to BoltDB.
+// View reads a snapshot, Update a LevelDB transaction
+type levelDBStore struct {
+	db *leveldb.DB
+}
+
+func openLevelDBStore(path string) (*levelDBStore, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &levelDBStore{db}, nil
+}
+
+func (s *levelDBStore) View(fn func(tx StoreTx) error) error {
+	snapshot, err := s.db.GetSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snapshot.Release()
+
+	tx := &levelDBTx{reader: snapshot}
+	defer tx.releaseIterators()
+
+	return fn(tx)
+}
+
+func (s *levelDBStore) Update(fn func(tx StoreTx) error) error {
+	transaction, err := s.db.OpenTransaction()
+	if err != nil {
+		return err
+	}
+
+	tx := &levelDBTx{reader: transaction, writer: transaction}
+	err = fn(tx)
+	tx.releaseIterators()
+	if err != nil {
+		transaction.Discard()
+		return err
+	}
+
+	return transaction.Commit()
+}
+
+func (s *levelDBStore) Close() error {
+	return s.db.Close()
+}
+
+// levelDBReader is what snapshots and transactions have in common
+type levelDBReader interface {
+	Get(key []byte, ro *opt.ReadOptions) ([]byte, error)
+	NewIterator(slice *util.Range, ro *opt.ReadOptions) iterator.Iterator
+}
+
+type levelDBTx struct {
+	reader levelDBReader
+	// writer is nil in read-only transactions
+	writer    *leveldb.Transaction
+	iterators []iterator.Iterator
+}
+
+func (t *levelDBTx) has(key []byte) bool {
+	_, err := t.reader.Get(key, nil)
+
+	return err == nil
+}
+
+func (t *levelDBTx) Bucket(name []byte) StoreBucket {
+	if !t.has(levelDBMarker(name)) {
+		return nil
+	}
+
+	return &levelDBBucket{t, levelDBPrefix(name)}
+}
+
+func (t *levelDBTx) CreateBucket(name []byte) (StoreBucket, error) {
+	if t.has(levelDBMarker(name)) {
+		return nil, errBucketExists
+	}
+
+	err := t.writer.Put(levelDBMarker(name), []byte{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &levelDBBucket{t, levelDBPrefix(name)}, nil
+}
+
+func (t *levelDBTx) CreateBucketIfNotExists(name []byte) (StoreBucket, error) {
+	if b := t.Bucket(name); b != nil {
+		return b, nil
+	}
+
+	return t.CreateBucket(name)
+}
+
+func (t *levelDBTx) DeleteBucket(name []byte) error {
+	if !t.has(levelDBMarker(name)) {
+		return errBucketNotFound
+	}
+
+	var keys [][]byte
+	it := t.reader.NewIterator(util.BytesPrefix(levelDBPrefix(name)), nil)
+	for it.Next() {
+		keys = append(keys, append([]byte{}, it.Key()...))
+	}
+	it.Release()
+
+	for _, key := range keys {
+		err := t.writer.Delete(key, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return t.writer.Delete(levelDBMarker(name), nil)
+}
+
+func (t *levelDBTx) releaseIterators() {
+	for _, it := range t.iterators {
+		it.Release()
+	}
+	t.iterators = nil
+}
+
+type levelDBBucket struct {
+	tx     *levelDBTx
+	prefix []byte
+}
+
+func (b *levelDBBucket) key(key []byte) []byte {
+	return append(append([]byte{}, b.prefix...), key...)
+}
+
+func (b *levelDBBucket) Get(key []byte) []byte {
+	value, err := b.tx.reader.Get(b.key(key), nil)
+	if err != nil {
+		return nil
+	}
+
+	return value
+}
+
+func (b *levelDBBucket) Put(key, value []byte) error {
+	return b.tx.writer.Put(b.key(key), value, nil)
+}
+
+func (b *levelDBBucket) Delete(key []byte) error {
+	return b.tx.writer.Delete(b.key(key), nil)
+}
+
+func (b *levelDBBucket) ForEach(fn func(k, v []byte) error) error {
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		err := fn(k, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *levelDBBucket) Cursor() StoreCursor {
+	it := b.tx.reader.NewIterator(util.BytesPrefix(b.prefix), nil)
+	b.tx.iterators = append(b.tx.iterators, it)
+
+	return &levelDBCursor{it, b.prefix}
+}
+
+// levelDBCursor strips the bucket prefix off the keys of an iterator
+type levelDBCursor struct {
+	it     iterator.Iterator
+	prefix []byte
+}
+
+func (c *levelDBCursor) current(ok bool) ([]byte, []byte) {
+	if !ok {
+		return nil, nil
+	}
+
+	return append([]byte{}, c.it.Key()[len(c.prefix):]...), append([]byte{}, c.it.Value()...)
+}
+
+func (c *levelDBCursor) First() ([]byte, []byte) {
+	return c.current(c.it.First())
+}
+
+func (c *levelDBCursor) Last() ([]byte, []byte) {
+	return c.current(c.it.Last())
+}
+
+func (c *levelDBCursor) Seek(seek []byte) ([]byte, []byte) {
+	return c.current(c.it.Seek(append(append([]byte{}, c.prefix...), seek...)))
+}
+
+func (c *levelDBCursor) Next() ([]byte, []byte) {
+	return c.current(c.it.Next())
+}
+
+func (c *levelDBCursor) Prev() ([]byte, []byte) {
+	return c.current(c.it.Prev())
+}
+
+func levelDBMarker(name []byte) []byte {
+	return append([]byte{levelDBBucketMarker}, name...)
+}
+
+// levelDBPrefix is put in front of the keys of bucket name, its length keeps "ab"+"c" apart from "a"+"bc"
+func levelDBPrefix(name []byte) []byte {
+	return append([]byte{levelDBBucketKey, byte(len(name))}, name...)
+}
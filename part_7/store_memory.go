@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+// memoryStore keeps a Store in memory, for tests. Update works on copies of the buckets it writes to,
+// which replace the originals when it commits
+type memoryStore struct {
+	mu      sync.RWMutex
+	buckets map[string]*memoryBucket
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{buckets: make(map[string]*memoryBucket)}
+}
+
+func (s *memoryStore) View(fn func(tx StoreTx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return fn(&memoryTx{buckets: s.buckets})
+}
+
+func (s *memoryStore) Update(fn func(tx StoreTx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &memoryTx{buckets: make(map[string]*memoryBucket), writable: true, copied: make(map[string]bool)}
+	for name, b := range s.buckets {
+		tx.buckets[name] = b
+	}
+
+	err := fn(tx)
+	if err != nil {
+		return err
+	}
+	s.buckets = tx.buckets
+
+	return nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+type memoryTx struct {
+	buckets  map[string]*memoryBucket
+	writable bool
+	// copied are the buckets this transaction already copied before writing to them
+	copied map[string]bool
+}
+
+func (t *memoryTx) Bucket(name []byte) StoreBucket {
+	if _, ok := t.buckets[string(name)]; !ok {
+		return nil
+	}
+
+	return &memoryTxBucket{t, string(name)}
+}
+
+func (t *memoryTx) CreateBucket(name []byte) (StoreBucket, error) {
+	if _, ok := t.buckets[string(name)]; ok {
+		return nil, errBucketExists
+	}
+
+	t.buckets[string(name)] = &memoryBucket{data: make(map[string][]byte)}
+	t.copied[string(name)] = true
+
+	return &memoryTxBucket{t, string(name)}, nil
+}
+
+func (t *memoryTx) CreateBucketIfNotExists(name []byte) (StoreBucket, error) {
+	if b := t.Bucket(name); b != nil {
+		return b, nil
+	}
+
+	return t.CreateBucket(name)
+}
+
+func (t *memoryTx) DeleteBucket(name []byte) error {
+	if _, ok := t.buckets[string(name)]; !ok {
+		return errBucketNotFound
+	}
+
+	delete(t.buckets, string(name))
+	delete(t.copied, string(name))
+
+	return nil
+}
+
+// memoryBucket holds the keys of a bucket, keys is kept sorted for cursors
+type memoryBucket struct {
+	data map[string][]byte
+	keys []string
+}
+
+func (b *memoryBucket) clone() *memoryBucket {
+	cloned := &memoryBucket{data: make(map[string][]byte, len(b.data)), keys: append([]string{}, b.keys...)}
+	for k, v := range b.data {
+		cloned.data[k] = v
+	}
+
+	return cloned
+}
+
+// memoryTxBucket is a bucket as seen from a transaction, it copies the bucket on the first write
+type memoryTxBucket struct {
+	tx   *memoryTx
+	name string
+}
+
+func (b *memoryTxBucket) writable() *memoryBucket {
+	if !b.tx.writable {
+		panic("memory store: write in a read-only transaction")
+	}
+
+	if !b.tx.copied[b.name] {
+		b.tx.buckets[b.name] = b.tx.buckets[b.name].clone()
+		b.tx.copied[b.name] = true
+	}
+
+	return b.tx.buckets[b.name]
+}
+
+func (b *memoryTxBucket) Get(key []byte) []byte {
+	return b.tx.buckets[b.name].data[string(key)]
+}
+
+func (b *memoryTxBucket) Put(key, value []byte) error {
+	bucket := b.writable()
+
+	if _, ok := bucket.data[string(key)]; !ok {
+		i := sort.SearchStrings(bucket.keys, string(key))
+		bucket.keys = append(bucket.keys, "")
+		copy(bucket.keys[i+1:], bucket.keys[i:])
+		bucket.keys[i] = string(key)
+	}
+	bucket.data[string(key)] = append([]byte{}, value...)
+
+	return nil
+}
+
+func (b *memoryTxBucket) Delete(key []byte) error {
+	bucket := b.writable()
+
+	if _, ok := bucket.data[string(key)]; !ok {
+		return nil
+	}
+	delete(bucket.data, string(key))
+
+	i := sort.SearchStrings(bucket.keys, string(key))
+	bucket.keys = append(bucket.keys[:i], bucket.keys[i+1:]...)
+
+	return nil
+}
+
+func (b *memoryTxBucket) ForEach(fn func(k, v []byte) error) error {
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		err := fn(k, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Cursor walks the keys the bucket had when it was created
+func (b *memoryTxBucket) Cursor() StoreCursor {
+	bucket := b.tx.buckets[b.name]
+
+	return &memoryCursor{bucket.data, append([]string{}, bucket.keys...), -1}
+}
+
+type memoryCursor struct {
+	data map[string][]byte
+	keys []string
+	pos  int
+}
+
+func (c *memoryCursor) current() ([]byte, []byte) {
+	if c.pos < 0 || c.pos >= len(c.keys) {
+		return nil, nil
+	}
+	key := c.keys[c.pos]
+
+	return []byte(key), c.data[key]
+}
+
+func (c *memoryCursor) First() ([]byte, []byte) {
+	c.pos = 0
+
+	return c.current()
+}
+
+func (c *memoryCursor) Last() ([]byte, []byte) {
+	c.pos = len(c.keys) - 1
+
+	return c.current()
+}
+
+func (c *memoryCursor) Seek(seek []byte) ([]byte, []byte) {
+	c.pos = sort.Search(len(c.keys), func(i int) bool {
+		return bytes.Compare([]byte(c.keys[i]), seek) >= 0
+	})
+
+	return c.current()
+}
+
+func (c *memoryCursor) Next() ([]byte, []byte) {
+	if c.pos < len(c.keys) {
+		c.pos++
+	}
+
+	return c.current()
+}
+
+func (c *memoryCursor) Prev() ([]byte, []byte) {
+	if c.pos >= 0 {
+		c.pos--
+	}
+
+	return c.current()
+}
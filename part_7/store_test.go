@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testStores opens an empty store of every backend
+func testStores(t testing.TB) map[string]Store {
+	dir := t.TempDir()
+
+	boltStore, err := openBoltStore(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	levelDBStore, err := openLevelDBStore(filepath.Join(dir, "test.leveldb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]Store{"memory": newMemoryStore(), boltBackend: boltStore, levelDBBackend: levelDBStore}
+	t.Cleanup(func() {
+		for _, store := range stores {
+			store.Close()
+		}
+	})
+
+	return stores
+}
+
+func TestStoreBuckets(t *testing.T) {
+	for name, store := range testStores(t) {
+		err := store.Update(func(tx StoreTx) error {
+			assert.Nil(t, tx.Bucket([]byte("a")), name)
+
+			b, err := tx.CreateBucket([]byte("a"))
+			assert.Nil(t, err, name)
+			assert.Nil(t, b.Put([]byte("k"), []byte("v")), name)
+
+			_, err = tx.CreateBucket([]byte("a"))
+			assert.Equal(t, errBucketExists, err, name)
+
+			_, err = tx.CreateBucketIfNotExists([]byte("ab"))
+			assert.Nil(t, err, name)
+
+			return nil
+		})
+		assert.Nil(t, err, name)
+
+		err = store.View(func(tx StoreTx) error {
+			assert.Equal(t, []byte("v"), tx.Bucket([]byte("a")).Get([]byte("k")), name)
+			assert.Nil(t, tx.Bucket([]byte("ab")).Get([]byte("k")), "Buckets don't share keys: "+name)
+
+			return nil
+		})
+		assert.Nil(t, err, name)
+
+		err = store.Update(func(tx StoreTx) error {
+			assert.Nil(t, tx.DeleteBucket([]byte("a")), name)
+			assert.Equal(t, errBucketNotFound, tx.DeleteBucket([]byte("a")), name)
+
+			return nil
+		})
+		assert.Nil(t, err, name)
+
+		err = store.View(func(tx StoreTx) error {
+			assert.Nil(t, tx.Bucket([]byte("a")), name)
+
+			return nil
+		})
+		assert.Nil(t, err, name)
+	}
+}
+
+func TestStoreRollback(t *testing.T) {
+	for name, store := range testStores(t) {
+		err := store.Update(func(tx StoreTx) error {
+			b, err := tx.CreateBucket([]byte("a"))
+			assert.Nil(t, err, name)
+
+			return b.Put([]byte("k"), []byte("v"))
+		})
+		assert.Nil(t, err, name)
+
+		failed := errors.New("failed")
+		err = store.Update(func(tx StoreTx) error {
+			b := tx.Bucket([]byte("a"))
+			assert.Nil(t, b.Put([]byte("k"), []byte("changed")), name)
+			assert.Nil(t, b.Delete([]byte("k")), name)
+
+			return failed
+		})
+		assert.Equal(t, failed, err, name)
+
+		err = store.View(func(tx StoreTx) error {
+			assert.Equal(t, []byte("v"), tx.Bucket([]byte("a")).Get([]byte("k")), "Failed updates are rolled back: "+name)
+
+			return nil
+		})
+		assert.Nil(t, err, name)
+	}
+}
+
+func TestStoreCursor(t *testing.T) {
+	for name, store := range testStores(t) {
+		err := store.Update(func(tx StoreTx) error {
+			b, err := tx.CreateBucket([]byte("a"))
+			assert.Nil(t, err, name)
+
+			for _, key := range []string{"c", "a", "e", "b"} {
+				assert.Nil(t, b.Put([]byte(key), []byte(key+key)), name)
+			}
+
+			return nil
+		})
+		assert.Nil(t, err, name)
+
+		err = store.View(func(tx StoreTx) error {
+			var keys []string
+			c := tx.Bucket([]byte("a")).Cursor()
+			for k, _ := c.First(); k != nil; k, _ = c.Next() {
+				keys = append(keys, string(k))
+			}
+			assert.Equal(t, []string{"a", "b", "c", "e"}, keys, name)
+
+			k, v := c.Seek([]byte("d"))
+			assert.Equal(t, "e", string(k), name)
+			assert.Equal(t, "ee", string(v), name)
+			k, _ = c.Next()
+			assert.Nil(t, k, name)
+
+			k, _ = c.Last()
+			assert.Equal(t, "e", string(k), name)
+			k, _ = c.Prev()
+			assert.Equal(t, "c", string(k), name)
+
+			return nil
+		})
+		assert.Nil(t, err, name)
+	}
+}
+
+func TestBlockchainInMemoryStore(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet.Address())
+
+	cbtx := NewCoinbaseTX(wallet.Address(), "")
+	bc.MineBlock([]*Transaction{cbtx})
+
+	assert.Equal(t, 1, bc.GetBestHeight())
+
+	block, err := bc.GetBlockByHeight(1)
+	assert.Nil(t, err)
+	assert.Equal(t, cbtx.ID, block.Transactions[0].ID)
+
+	found, err := bc.FindTransaction(cbtx.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, cbtx.ID, found.ID, "Transactions are found through the index")
+}
+
+func BenchmarkStorePut(b *testing.B) {
+	for name, store := range testStores(b) {
+		b.Run(name, func(b *testing.B) {
+			err := store.Update(func(tx StoreTx) error {
+				_, err := tx.CreateBucketIfNotExists([]byte("bench"))
+
+				return err
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			for i := 0; i < b.N; i++ {
+				err := store.Update(func(tx StoreTx) error {
+					return tx.Bucket([]byte("bench")).Put([]byte(fmt.Sprintf("key%d", i)), make([]byte, 256))
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkStoreGet(b *testing.B) {
+	for name, store := range testStores(b) {
+		b.Run(name, func(b *testing.B) {
+			err := store.Update(func(tx StoreTx) error {
+				bucket, err := tx.CreateBucketIfNotExists([]byte("bench"))
+				if err != nil {
+					return err
+				}
+
+				for i := 0; i < 1000; i++ {
+					err = bucket.Put([]byte(fmt.Sprintf("key%d", i)), make([]byte, 256))
+					if err != nil {
+						return err
+					}
+				}
+
+				return nil
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			for i := 0; i < b.N; i++ {
+				err := store.View(func(tx StoreTx) error {
+					tx.Bucket([]byte("bench")).Get([]byte(fmt.Sprintf("key%d", i%1000)))
+
+					return nil
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -13,15 +13,20 @@ import (
 	"encoding/hex"
 	"fmt"
 	"log"
+	"time"
 )
 
 const subsidy = 10
 
+// Lock times below lockTimeThreshold are block heights, the others are unix timestamps
+const lockTimeThreshold = 500000000
+
 // Transaction represents a Bitcoin transaction
 type Transaction struct {
-	ID   []byte
-	Vin  []TXInput
-	Vout []TXOutput
+	ID       []byte
+	Vin      []TXInput
+	Vout     []TXOutput
+	LockTime int64
 }
 
 // IsCoinbase checks whether the transaction is coinbase
@@ -29,6 +34,57 @@ func (tx Transaction) IsCoinbase() bool {
 	return len(tx.Vin) == 1 && len(tx.Vin[0].Txid) == 0 && tx.Vin[0].Vout == -1
 }
 
+// IsFinal checks the absolute lock time of the transaction against a block height and time
+func (tx Transaction) IsFinal(height int, blockTime int64) bool {
+	if tx.LockTime == 0 {
+		return true
+	}
+
+	if tx.LockTime < lockTimeThreshold {
+		if tx.LockTime < int64(height) {
+			return true
+		}
+	} else if tx.LockTime < blockTime {
+		return true
+	}
+
+	// Lock time is ignored when every input opts out of it
+	for _, vin := range tx.Vin {
+		if vin.Sequence != sequenceFinal {
+			return false
+		}
+	}
+
+	return true
+}
+
+// lockTimeReached checks whether a transaction with lockTime and inputs with sequence
+// passes its absolute lock time in a block with the given height and time
+func lockTimeReached(lockTime int64, sequence uint32, height int, blockTime int64) bool {
+	tx := Transaction{LockTime: lockTime, Vin: []TXInput{{Sequence: sequence}}}
+
+	return tx.IsFinal(height, blockTime)
+}
+
+// SetLockTime sets the lock time of the transaction and the sequence of all its inputs.
+// It has to be called before the transaction is signed
+func (tx *Transaction) SetLockTime(lockTime int64, sequence uint32) {
+	tx.LockTime = lockTime
+	for i := range tx.Vin {
+		tx.Vin[i].Sequence = sequence
+	}
+
+	tx.ID = nil
+	tx.ID = tx.Hash()
+}
+
+// gob numbers types in the order a process first encodes them, and the numbers are part of the encoding.
+// Transactions and UTXO set entries are encoded here first, so their hashes are the same in every process
+func init() {
+	Transaction{}.Serialize()
+	UTXOEntry{}.Serialize()
+}
+
 // Serialize returns a serialized Transaction
 func (tx Transaction) Serialize() []byte {
 	var encoded bytes.Buffer
@@ -54,10 +110,25 @@ func (tx *Transaction) Hash() []byte {
 	return hash[:]
 }
 
-// Sign signs each input of a Transaction
-func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) {
+// UnsignedHash returns the hash of the Transaction without the public keys and signatures of its inputs,
+// the ID of a transaction is set before it's signed
+func (tx *Transaction) UnsignedHash() []byte {
+	txCopy := *tx
+	if !tx.IsCoinbase() {
+		txCopy.Vin = nil
+		for _, vin := range tx.Vin {
+			txCopy.Vin = append(txCopy.Vin, TXInput{Txid: vin.Txid, Vout: vin.Vout, Sequence: vin.Sequence, RedeemScript: vin.RedeemScript})
+		}
+	}
+
+	return txCopy.Hash()
+}
+
+// Sign signs the inputs of a Transaction spending outputs locked with privKey's public key
+// and returns the number of inputs signed
+func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) int {
 	if tx.IsCoinbase() {
-		return
+		return 0
 	}
 
 	for _, vin := range tx.Vin {
@@ -66,24 +137,80 @@ func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transac
 		}
 	}
 
+	pubKey := encodePubKey(privKey.PublicKey)
+	pubKeyHash := HashPubKey(pubKey)
 	txCopy := tx.TrimmedCopy()
+	signed := 0
 
 	for inID, vin := range txCopy.Vin {
+		if len(tx.Vin[inID].RedeemScript) != 0 {
+			continue
+		}
+
 		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
-		txCopy.Vin[inID].Signature = nil
-		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
+		prevOut := prevTx.Vout[vin.Vout]
+		if !prevOut.IsLockedWithKey(pubKeyHash) {
+			continue
+		}
+		dataToSign := txCopy.signatureData(inID, prevOut)
+
+		tx.Vin[inID].PubKey = pubKey
+		tx.Vin[inID].Signature = signData(privKey, dataToSign)
+		signed++
+	}
+
+	return signed
+}
 
-		dataToSign := fmt.Sprintf("%x\n", txCopy)
+// SignMultisig adds privKey's signature to every multisig input whose redeem script contains its public key
+// and returns the number of inputs signed
+func (tx *Transaction) SignMultisig(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) int {
+	if tx.IsCoinbase() {
+		return 0
+	}
+
+	pubKey := encodePubKey(privKey.PublicKey)
+	txCopy := tx.TrimmedCopy()
+	signed := 0
 
-		r, s, err := ecdsa.Sign(rand.Reader, &privKey, []byte(dataToSign))
+	for inID, vin := range tx.Vin {
+		if len(vin.RedeemScript) == 0 {
+			continue
+		}
+
+		script, err := DeserializeMultisigScript(vin.RedeemScript)
 		if err != nil {
 			log.Panic(err)
 		}
-		signature := append(r.Bytes(), s.Bytes()...)
+		keyIndex := script.KeyIndex(pubKey)
+		if keyIndex < 0 {
+			continue
+		}
 
-		tx.Vin[inID].Signature = signature
-		txCopy.Vin[inID].PubKey = nil
+		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
+		if prevTx.ID == nil {
+			log.Panic("ERROR: Previous transaction is not correct")
+		}
+		dataToSign := txCopy.signatureData(inID, prevTx.Vout[vin.Vout])
+
+		if len(tx.Vin[inID].Signatures) != len(script.PubKeys) {
+			tx.Vin[inID].Signatures = make([][]byte, len(script.PubKeys))
+		}
+		tx.Vin[inID].Signatures[keyIndex] = signData(privKey, dataToSign)
+		signed++
 	}
+
+	return signed
+}
+
+// signatureData returns the data signed for input inID of a trimmed copy
+func (tx *Transaction) signatureData(inID int, prevOut TXOutput) []byte {
+	tx.Vin[inID].Signature = nil
+	tx.Vin[inID].PubKey = prevOut.PubKeyHash
+	dataToSign := tx.Serialize()
+	tx.Vin[inID].PubKey = nil
+
+	return dataToSign
 }
 
 // String returns a human-readable representation of a transaction
@@ -91,19 +218,33 @@ func (tx Transaction) String() string {
 	var lines []string
 
 	lines = append(lines, fmt.Sprintf("--- Transaction %x:", tx.ID))
+	if tx.LockTime != 0 {
+		lines = append(lines, fmt.Sprintf("     Lock time: %d", tx.LockTime))
+	}
 
 	for i, input := range tx.Vin {
 
 		lines = append(lines, fmt.Sprintf("     Input %d:", i))
 		lines = append(lines, fmt.Sprintf("       TXID:      %x", input.Txid))
 		lines = append(lines, fmt.Sprintf("       Out:       %d", input.Vout))
+		lines = append(lines, fmt.Sprintf("       Sequence:  %d", input.Sequence))
 		lines = append(lines, fmt.Sprintf("       Signature: %x", input.Signature))
 		lines = append(lines, fmt.Sprintf("       PubKey:    %x", input.PubKey))
+		if len(input.RedeemScript) > 0 {
+			lines = append(lines, fmt.Sprintf("       Redeem:    %x", input.RedeemScript))
+			for j, signature := range input.Signatures {
+				lines = append(lines, fmt.Sprintf("       Sig %d:     %x", j, signature))
+			}
+		}
 	}
 
 	for i, output := range tx.Vout {
 		lines = append(lines, fmt.Sprintf("     Output %d:", i))
 		lines = append(lines, fmt.Sprintf("       Value:  %d", output.Value))
+		if output.IsUnspendable() {
+			lines = append(lines, fmt.Sprintf("       Data:   %x", output.Data))
+			continue
+		}
 		lines = append(lines, fmt.Sprintf("       Script: %x", output.PubKeyHash))
 	}
 
@@ -116,20 +257,26 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 	var outputs []TXOutput
 
 	for _, vin := range tx.Vin {
-		inputs = append(inputs, TXInput{vin.Txid, vin.Vout, nil, nil})
+		inputs = append(inputs, TXInput{Txid: vin.Txid, Vout: vin.Vout, Sequence: vin.Sequence})
 	}
 
 	for _, vout := range tx.Vout {
-		outputs = append(outputs, TXOutput{vout.Value, vout.PubKeyHash})
+		outputs = append(outputs, TXOutput{vout.Value, vout.PubKeyHash, vout.Type, vout.Data})
 	}
 
-	txCopy := Transaction{tx.ID, inputs, outputs}
+	txCopy := Transaction{tx.ID, inputs, outputs, tx.LockTime}
 
 	return txCopy
 }
 
 // Verify verifies signatures of Transaction inputs
 func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
+	for _, vout := range tx.Vout {
+		if !vout.IsValid() {
+			return false
+		}
+	}
+
 	if tx.IsCoinbase() {
 		return true
 	}
@@ -141,39 +288,84 @@ func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
 	}
 
 	txCopy := tx.TrimmedCopy()
-	curve := elliptic.P256()
 
 	for inID, vin := range tx.Vin {
 		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
-		txCopy.Vin[inID].Signature = nil
-		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
-
-		r := big.Int{}
-		s := big.Int{}
-		sigLen := len(vin.Signature)
-		r.SetBytes(vin.Signature[:(sigLen / 2)])
-		s.SetBytes(vin.Signature[(sigLen / 2):])
-
-		x := big.Int{}
-		y := big.Int{}
-		keyLen := len(vin.PubKey)
-		x.SetBytes(vin.PubKey[:(keyLen / 2)])
-		y.SetBytes(vin.PubKey[(keyLen / 2):])
-
-		dataToVerify := fmt.Sprintf("%x\n", txCopy)
+		prevOut := prevTx.Vout[vin.Vout]
+		if prevOut.IsUnspendable() {
+			return false
+		}
+		dataToVerify := txCopy.signatureData(inID, prevOut)
+
+		if prevOut.Type == scriptHashOutput {
+			if bytes.Compare(HashPubKey(vin.RedeemScript), prevOut.PubKeyHash) != 0 {
+				return false
+			}
+			script, err := DeserializeMultisigScript(vin.RedeemScript)
+			if err != nil {
+				return false
+			}
+			if !script.Verify(dataToVerify, vin.Signatures) {
+				return false
+			}
+			continue
+		}
 
-		rawPubKey := ecdsa.PublicKey{curve, &x, &y}
-		if ecdsa.Verify(&rawPubKey, []byte(dataToVerify), &r, &s) == false {
+		if !vin.UsesKey(prevOut.PubKeyHash) {
+			return false
+		}
+		if !verifySignature(vin.PubKey, dataToVerify, vin.Signature) {
 			return false
 		}
-		txCopy.Vin[inID].PubKey = nil
 	}
 
 	return true
 }
 
+// signData signs the SHA-256 of data with privKey and returns r and s concatenated
+func signData(privKey ecdsa.PrivateKey, data []byte) []byte {
+	hash := sha256.Sum256(data)
+
+	r, s, err := ecdsa.Sign(rand.Reader, &privKey, hash[:])
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// r and s are padded to the same width, so verifySignature can split them in the middle
+	size := (privKey.Curve.Params().BitSize + 7) / 8
+	signature := make([]byte, 2*size)
+	r.FillBytes(signature[:size])
+	s.FillBytes(signature[size:])
+
+	return signature
+}
+
+// verifySignature checks a signature made by signData against a raw public key
+func verifySignature(pubKey, data, signature []byte) bool {
+	if len(pubKey) == 0 || len(signature) == 0 {
+		return false
+	}
+
+	r := big.Int{}
+	s := big.Int{}
+	sigLen := len(signature)
+	r.SetBytes(signature[:(sigLen / 2)])
+	s.SetBytes(signature[(sigLen / 2):])
+
+	x := big.Int{}
+	y := big.Int{}
+	keyLen := len(pubKey)
+	x.SetBytes(pubKey[:(keyLen / 2)])
+	y.SetBytes(pubKey[(keyLen / 2):])
+
+	rawPubKey := ecdsa.PublicKey{Curve: elliptic.P256(), X: &x, Y: &y}
+	hash := sha256.Sum256(data)
+
+	return ecdsa.Verify(&rawPubKey, hash[:], &r, &s)
+}
+
 // NewCoinbaseTX creates a new coinbase transaction
-func NewCoinbaseTX(to, data string) *Transaction {
+func NewCoinbaseTX(to Address, data string) *Transaction {
 	if data == "" {
 		randData := make([]byte, 20)
 		_, err := rand.Read(randData)
@@ -184,27 +376,101 @@ func NewCoinbaseTX(to, data string) *Transaction {
 		data = fmt.Sprintf("%x", randData)
 	}
 
-	txin := TXInput{[]byte{}, -1, nil, []byte(data)}
+	txin := TXInput{Txid: []byte{}, Vout: -1, PubKey: []byte(data)}
 	txout := NewTXOutput(subsidy, to)
-	tx := Transaction{nil, []TXInput{txin}, []TXOutput{*txout}}
+	tx := Transaction{nil, []TXInput{txin}, []TXOutput{*txout}, 0}
+	tx.ID = tx.Hash()
+
+	return &tx
+}
+
+// NewUTXOTransaction creates a transaction paying recipients from outputs of the wallets, change goes to the change address.
+// Every input is signed with the key of the wallet it belongs to
+func NewUTXOTransaction(wallets []*Wallet, recipients []Recipient, change Address, selector CoinSelector, lockTime int64, sequence uint32, UTXOSet *UTXOSet) *Transaction {
+	var from []Address
+	for _, wallet := range wallets {
+		if wallet.IsLocked() {
+			log.Panic("ERROR: Wallet is locked")
+		}
+		from = append(from, wallet.Address())
+	}
+
+	tx := NewUnsignedTransaction(from, recipients, change, selector, nil, UTXOSet)
+	tx.SetLockTime(lockTime, sequence)
+
+	// The relative lock depends on the selected outputs, a locked transaction isn't signed
+	bc := UTXOSet.Blockchain
+	if !bc.IsTransactionFinal(tx, bc.GetBestHeight()+1, time.Now().Unix()) {
+		log.Panic("ERROR: Transaction is locked, use createrawtx and send it with sendrawtx once it is final")
+	}
+
+	for _, wallet := range wallets {
+		UTXOSet.Blockchain.SignTransaction(tx, wallet.PrivateKey)
+	}
+
+	return tx
+}
+
+// NewUnsignedTransaction creates a transaction paying recipients from outputs of the from addresses without signing it.
+// Outputs are picked by selector, change goes to the change address or back to the first from address when it's the zero Address.
+// Inputs spending a multisig address get its redeem script from redeemScripts, keyed by the hex-encoded script hash
+func NewUnsignedTransaction(from []Address, recipients []Recipient, change Address, selector CoinSelector, redeemScripts map[string][]byte, UTXOSet *UTXOSet) *Transaction {
+	var inputs []TXInput
+	var outputs []TXOutput
+
+	var utxos []UnspentOutput
+	for _, address := range from {
+		utxos = append(utxos, UTXOSet.FindUnspentOutputs(address.Hash)...)
+	}
+
+	amount := totalAmount(recipients)
+	selected, err := selector(utxos, amount)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Build a list of inputs, public keys and signatures are added when signing
+	acc := 0
+	for _, utxo := range selected {
+		input := TXInput{Txid: utxo.TxID, Vout: utxo.Vout, RedeemScript: redeemScripts[hex.EncodeToString(utxo.Output.PubKeyHash)]}
+		inputs = append(inputs, input)
+		acc += utxo.Output.Value
+	}
+
+	// Build a list of outputs
+	for _, recipient := range recipients {
+		outputs = append(outputs, *NewTXOutput(recipient.Amount, recipient.Address))
+	}
+	if change.Hash == nil {
+		change = from[0]
+	}
+	if acc > amount {
+		outputs = append(outputs, *NewTXOutput(acc-amount, change)) // a change
+	}
+
+	tx := Transaction{nil, inputs, outputs, 0}
 	tx.ID = tx.Hash()
 
 	return &tx
 }
 
-// NewUTXOTransaction creates a new transaction
-func NewUTXOTransaction(wallet *Wallet, to string, amount int, UTXOSet *UTXOSet) *Transaction {
+// NewDataTransaction creates a transaction publishing data in an unspendable output.
+// It spends at least one output of the wallet and sends it back as change
+func NewDataTransaction(wallet *Wallet, data []byte, UTXOSet *UTXOSet) *Transaction {
 	var inputs []TXInput
 	var outputs []TXOutput
 
+	if wallet.IsLocked() {
+		log.Panic("ERROR: Wallet is locked")
+	}
+
 	pubKeyHash := HashPubKey(wallet.PublicKey)
-	acc, validOutputs := UTXOSet.FindSpendableOutputs(pubKeyHash, amount)
+	acc, validOutputs := UTXOSet.FindSpendableOutputs(pubKeyHash, 1)
 
-	if acc < amount {
+	if acc < 1 {
 		log.Panic("ERROR: Not enough funds")
 	}
 
-	// Build a list of inputs
 	for txid, outs := range validOutputs {
 		txID, err := hex.DecodeString(txid)
 		if err != nil {
@@ -212,19 +478,16 @@ func NewUTXOTransaction(wallet *Wallet, to string, amount int, UTXOSet *UTXOSet)
 		}
 
 		for _, out := range outs {
-			input := TXInput{txID, out, nil, wallet.PublicKey}
+			input := TXInput{Txid: txID, Vout: out}
 			inputs = append(inputs, input)
 		}
 	}
 
-	// Build a list of outputs
-	from := fmt.Sprintf("%s", wallet.GetAddress())
-	outputs = append(outputs, *NewTXOutput(amount, to))
-	if acc > amount {
-		outputs = append(outputs, *NewTXOutput(acc-amount, from)) // a change
-	}
+	// The change comes first, then the data output
+	outputs = append(outputs, *NewTXOutput(acc, wallet.Address()))
+	outputs = append(outputs, *NewDataOutput(data))
 
-	tx := Transaction{nil, inputs, outputs}
+	tx := Transaction{nil, inputs, outputs, 0}
 	tx.ID = tx.Hash()
 	UTXOSet.Blockchain.SignTransaction(&tx, wallet.PrivateKey)
 
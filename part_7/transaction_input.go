@@ -2,12 +2,30 @@ package main
 
 import "bytes"
 
+const sequenceFinal = 0xffffffff
+
+// Relative lock time encoding of TXInput.Sequence
+const (
+	// sequenceLockTimeDisableFlag turns off the relative lock time of an input
+	sequenceLockTimeDisableFlag = 1 << 31
+	// sequenceLockTimeTypeFlag makes the relative lock time count seconds instead of blocks
+	sequenceLockTimeTypeFlag = 1 << 22
+	sequenceLockTimeMask     = 0x0000ffff
+	// sequenceLockTimeGranularity is the number of bits seconds are shifted by, i.e. units of 512 seconds
+	sequenceLockTimeGranularity = 9
+)
+
 // TXInput represents a transaction input
 type TXInput struct {
 	Txid      []byte
 	Vout      int
+	Sequence  uint32
 	Signature []byte
 	PubKey    []byte
+	// RedeemScript and Signatures are used instead of Signature and PubKey
+	// when the input spends a multisig (script hash) output
+	RedeemScript []byte
+	Signatures   [][]byte
 }
 
 // UsesKey checks whether the address initiated the transaction
@@ -2,63 +2,71 @@ package main
 
 import (
 	"bytes"
-	"encoding/gob"
 	"log"
 )
 
+// Output types
+const (
+	pubKeyHashOutput = iota
+	scriptHashOutput
+	nullDataOutput
+)
+
+// maxDataCarrierSize limits the payload of a data output
+const maxDataCarrierSize = 80
+
 // TXOutput represents a transaction output
 type TXOutput struct {
 	Value      int
 	PubKeyHash []byte
+	Type       int
+	Data       []byte
 }
 
 // Lock signs the output
-func (out *TXOutput) Lock(address []byte) {
-	pubKeyHash := Base58Decode(address)
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
-	out.PubKeyHash = pubKeyHash
+func (out *TXOutput) Lock(address Address) {
+	out.Type = address.Type
+	out.PubKeyHash = address.Hash
 }
 
 // IsLockedWithKey checks if the output can be used by the owner of the pubkey
 func (out *TXOutput) IsLockedWithKey(pubKeyHash []byte) bool {
-	return bytes.Compare(out.PubKeyHash, pubKeyHash) == 0
+	return !out.IsUnspendable() && bytes.Compare(out.PubKeyHash, pubKeyHash) == 0
 }
 
-// NewTXOutput create a new TXOutput
-func NewTXOutput(value int, address string) *TXOutput {
-	txo := &TXOutput{value, nil}
-	txo.Lock([]byte(address))
-
-	return txo
+// IsUnspendable checks whether the output is a data output that can never be spent
+func (out *TXOutput) IsUnspendable() bool {
+	return out.Type == nullDataOutput
 }
 
-// TXOutputs collects TXOutput
-type TXOutputs struct {
-	Outputs []TXOutput
+// IsValid checks that data outputs carry no value and at most maxDataCarrierSize bytes, and that
+// the other outputs carry no data
+func (out *TXOutput) IsValid() bool {
+	if out.IsUnspendable() {
+		return out.Value == 0 && len(out.Data) <= maxDataCarrierSize
+	}
+
+	return len(out.Data) == 0
 }
 
-// Serialize serializes TXOutputs
-func (outs TXOutputs) Serialize() []byte {
-	var buff bytes.Buffer
+// Address returns the address the output is locked to
+func (out *TXOutput) Address() Address {
+	return Address{activeNetwork, out.Type, out.PubKeyHash, base58Encoding}
+}
 
-	enc := gob.NewEncoder(&buff)
-	err := enc.Encode(outs)
-	if err != nil {
-		log.Panic(err)
-	}
+// NewTXOutput create a new TXOutput
+func NewTXOutput(value int, address Address) *TXOutput {
+	txo := &TXOutput{value, nil, pubKeyHashOutput, nil}
+	txo.Lock(address)
 
-	return buff.Bytes()
+	return txo
 }
 
-// DeserializeOutputs deserializes TXOutputs
-func DeserializeOutputs(data []byte) TXOutputs {
-	var outputs TXOutputs
-
-	dec := gob.NewDecoder(bytes.NewReader(data))
-	err := dec.Decode(&outputs)
-	if err != nil {
-		log.Panic(err)
+// NewDataOutput creates a provably unspendable output carrying data
+func NewDataOutput(data []byte) *TXOutput {
+	if len(data) > maxDataCarrierSize {
+		log.Panicf("ERROR: Data output can't be larger than %d bytes", maxDataCarrierSize)
 	}
 
-	return outputs
+	return &TXOutput{0, nil, nullDataOutput, data}
 }
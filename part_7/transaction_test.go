@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionIsFinal(t *testing.T) {
+	tx := Transaction{nil, []TXInput{{Txid: []byte("prev"), Vout: 0}}, nil, 0}
+	assert.True(t, tx.IsFinal(1, 1000), "No lock time")
+
+	tx.SetLockTime(10, 0)
+	assert.False(t, tx.IsFinal(10, 1000), "Height lock is not reached")
+	assert.True(t, tx.IsFinal(11, 1000), "Height lock is reached")
+
+	tx.SetLockTime(lockTimeThreshold+100, 0)
+	assert.False(t, tx.IsFinal(1000, lockTimeThreshold+100), "Time lock is not reached")
+	assert.True(t, tx.IsFinal(1000, lockTimeThreshold+101), "Time lock is reached")
+
+	tx.SetLockTime(10, sequenceFinal)
+	assert.True(t, tx.IsFinal(1, 1000), "Final inputs disable the lock time")
+}
+
+func TestNewUTXOTransactionLocked(t *testing.T) {
+	sender := NewWallet()
+	bc := newTestBlockchain(t, sender.Address())
+	UTXOSet := UTXOSet{bc}
+	UTXOSet.Reindex()
+
+	assert.False(t, lockTimeReached(10, 0, 1, 1000), "Height lock is checked before outputs are selected")
+	assert.True(t, lockTimeReached(10, sequenceFinal, 1, 1000))
+
+	selector, err := GetCoinSelector(defaultCoinSelection)
+	assert.Nil(t, err)
+	recipients := []Recipient{{NewWallet().Address(), 4}}
+	assert.Panics(t, func() {
+		NewUTXOTransaction([]*Wallet{sender}, recipients, sender.Address(), selector, 0, 5, &UTXOSet)
+	}, "Relative lock of the selected outputs is not reached")
+
+	tx := NewUTXOTransaction([]*Wallet{sender}, recipients, sender.Address(), selector, 0, 1, &UTXOSet)
+	assert.True(t, bc.VerifyTransaction(tx), "Relative lock is reached in the next block")
+}
+
+func TestDataOutput(t *testing.T) {
+	out := NewDataOutput([]byte("document hash"))
+
+	assert.True(t, out.IsUnspendable())
+	assert.Equal(t, 0, out.Value)
+	assert.False(t, out.IsLockedWithKey(nil), "Data outputs aren't locked with any key")
+	assert.Panics(t, func() { NewDataOutput(make([]byte, maxDataCarrierSize+1)) }, "Data size is limited")
+}
+
+func TestVerifyOutputs(t *testing.T) {
+	coinbase := NewCoinbaseTX(NewWallet().Address(), "")
+	assert.True(t, coinbase.Verify(nil))
+
+	spendable := NewTXOutput(1, NewWallet().Address())
+	spendable.Data = []byte("not a data output")
+	coinbase.Vout = append(coinbase.Vout, *spendable)
+	assert.False(t, coinbase.Verify(nil), "Spendable outputs can't carry data")
+
+	coinbase.Vout[1] = TXOutput{0, nil, nullDataOutput, make([]byte, maxDataCarrierSize+1)}
+	assert.False(t, coinbase.Verify(nil), "Coinbase data outputs are limited too")
+}
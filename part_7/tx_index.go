@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"log"
+)
+
+const txIndexBucket = "txindex"
+
+var errTxIndexMissing = errors.New("Transaction index is missing, run reindextxs to build it")
+
+// TxIndex maps the ID of every transaction of the main chain to its block and position in it
+type TxIndex struct {
+	Blockchain *Blockchain
+}
+
+// IsEnabled checks whether the database has a transaction index, older databases need Reindex first
+func (ti TxIndex) IsEnabled() bool {
+	enabled := false
+
+	err := ti.Blockchain.db.View(func(tx StoreTx) error {
+		enabled = tx.Bucket([]byte(txIndexBucket)) != nil
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return enabled
+}
+
+// Reindex builds the transaction index from the genesis block
+func (ti TxIndex) Reindex() {
+	err := ti.Blockchain.db.Update(func(tx StoreTx) error {
+		err := tx.DeleteBucket([]byte(txIndexBucket))
+		if err != nil && err != errBucketNotFound {
+			log.Panic(err)
+		}
+
+		_, err = tx.CreateBucket([]byte(txIndexBucket))
+
+		return err
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	ti.Sync()
+}
+
+// Sync brings the transaction index up to the tip of the chain
+func (ti TxIndex) Sync() {
+	if ti.IsEnabled() {
+		syncChainIndex(ti.Blockchain, ti)
+	}
+}
+
+// ConnectBlock indexes the transactions of a block added to the main chain
+func (ti TxIndex) ConnectBlock(block *Block) {
+	err := ti.Blockchain.db.Update(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(txIndexBucket))
+
+		for i, transaction := range block.Transactions {
+			err := b.Put(txIndexKey(transaction.ID), txIndexValue(block.Hash, i))
+			if err != nil {
+				log.Panic(err)
+			}
+		}
+
+		return b.Put([]byte("l"), block.Hash)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// DisconnectBlock removes the transactions of a block that left the main chain
+func (ti TxIndex) DisconnectBlock(block *Block) {
+	err := ti.Blockchain.db.Update(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(txIndexBucket))
+
+		for _, transaction := range block.Transactions {
+			value := b.Get(txIndexKey(transaction.ID))
+			if value == nil || bytes.Compare(value[:len(value)-4], block.Hash) != 0 {
+				continue
+			}
+
+			err := b.Delete(txIndexKey(transaction.ID))
+			if err != nil {
+				log.Panic(err)
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			return b.Delete([]byte("l"))
+		}
+
+		return b.Put([]byte("l"), block.PrevBlockHash)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// Find returns the block containing a transaction of the main chain and its position in the block
+func (ti TxIndex) Find(ID []byte) (*Block, int, error) {
+	var blockHash []byte
+	position := 0
+
+	err := ti.Blockchain.db.View(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(txIndexBucket))
+		if b == nil {
+			return errTxIndexMissing
+		}
+
+		value := b.Get(txIndexKey(ID))
+		if value == nil {
+			return errors.New("Transaction is not found")
+		}
+
+		blockHash = append(blockHash, value[:len(value)-4]...)
+		position = int(binary.BigEndian.Uint32(value[len(value)-4:]))
+
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	block, err := ti.Blockchain.GetBlock(blockHash)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &block, position, nil
+}
+
+func (ti TxIndex) syncedHash() []byte {
+	return indexTip(ti.Blockchain.db, txIndexBucket)
+}
+
+// txIndexKey prefixes transaction IDs, so none can collide with the "l" key
+func txIndexKey(ID []byte) []byte {
+	return append([]byte("t"), ID...)
+}
+
+func txIndexValue(blockHash []byte, position int) []byte {
+	value := append([]byte{}, blockHash...)
+
+	return binary.BigEndian.AppendUint32(value, uint32(position))
+}
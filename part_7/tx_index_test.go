@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxIndexReorg(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet.Address())
+	genesis := bc.tip
+	index := TxIndex{bc}
+	mined := bc.MineBlock([]*Transaction{NewCoinbaseTX(wallet.Address(), "")})
+	index.Sync()
+
+	block, position, err := index.Find(mined.Transactions[0].ID)
+	assert.Nil(t, err)
+	assert.Equal(t, mined.Hash, block.Hash)
+	assert.Equal(t, 0, position)
+
+	// A longer branch from the genesis block takes over
+	side := NewBlock([]*Transaction{NewCoinbaseTX(wallet.Address(), "side")}, genesis, 1)
+	bc.AddBlock(side)
+	sideTip := NewBlock([]*Transaction{NewCoinbaseTX(wallet.Address(), "")}, side.Hash, 2)
+	bc.AddBlock(sideTip)
+	index.Sync()
+
+	assert.Equal(t, sideTip.Hash, index.syncedHash())
+	_, _, err = index.Find(mined.Transactions[0].ID)
+	assert.NotNil(t, err, "Transactions of disconnected blocks are removed")
+	block, _, err = index.Find(side.Transactions[0].ID)
+	assert.Nil(t, err)
+	assert.Equal(t, side.Hash, block.Hash)
+
+	// Disconnecting a block that isn't the one indexed for a transaction keeps its entry
+	index.DisconnectBlock(mined)
+	block, _, err = index.Find(side.Transactions[0].ID)
+	assert.Nil(t, err)
+	assert.Equal(t, side.Hash, block.Hash)
+
+	index.DisconnectBlock(sideTip)
+	assert.Equal(t, side.Hash, index.syncedHash())
+	_, _, err = index.Find(sideTip.Transactions[0].ID)
+	assert.NotNil(t, err)
+
+	index.Sync()
+	block, _, err = index.Find(sideTip.Transactions[0].ID)
+	assert.Nil(t, err)
+	assert.Equal(t, sideTip.Hash, block.Hash)
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"bytes"
 	"encoding/binary"
+	"io/ioutil"
 	"log"
+	"os"
 )
 
 // IntToHex converts an int64 to a byte array
@@ -23,3 +25,19 @@ func ReverseBytes(data []byte) {
 		data[i], data[j] = data[j], data[i]
 	}
 }
+
+// writePrivateFile writes data to path with mode 0600. WriteFile keeps the mode of an existing file, so the data
+// is written to a new file which then replaces the old one
+func writePrivateFile(path string, data []byte) {
+	tmpFile := path + ".tmp"
+	os.Remove(tmpFile)
+	err := ioutil.WriteFile(tmpFile, data, 0600)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = os.Rename(tmpFile, path)
+	if err != nil {
+		log.Panic(err)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"sort"
+	"sync"
+)
+
+// defaultUTXOCacheSize is the memory budget of a node's UTXO cache, in MiB
+const defaultUTXOCacheSize = 32
+
+// utxoCacheEntryOverhead estimates the memory taken by a cache entry besides its byte slices
+const utxoCacheEntryOverhead = 160
+
+// utxoCacheEntry is an output created or spent since the last flush, entry is nil for spent outputs
+type utxoCacheEntry struct {
+	entry *UTXOEntry
+	// fresh outputs aren't in the chainstate bucket, nothing has to be deleted when they're spent
+	fresh bool
+}
+
+// utxoCache keeps changes to the chainstate bucket and their undo records in memory, so blocks update the UTXO set without touching the store.
+// Changed entries are written back in one transaction with the block they're up to date with, the chainstate
+// bucket then always matches its best block marker and a node that stops without a flush syncs forward from there
+type utxoCache struct {
+	mu      sync.Mutex
+	budget  int64
+	size    int64
+	entries map[string]*utxoCacheEntry
+	// best is the block the cached UTXO set is up to date with, nil when nothing changed since the last flush
+	best []byte
+	// undo are the undo records of the blocks connected since the last flush
+	undo []utxoUndo
+}
+
+// newUTXOCache returns an empty cache that flushes once its entries take more than budget bytes
+func newUTXOCache(budget int64) *utxoCache {
+	return &utxoCache{budget: budget, entries: make(map[string]*utxoCacheEntry)}
+}
+
+// Flush writes the outputs changed in the cache to the chainstate bucket, together with the block
+// they're up to date with, and empties the cache
+func (u UTXOSet) Flush() {
+	cache := u.Blockchain.utxoCache
+	if cache == nil {
+		return
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.flush(u.Blockchain.db)
+}
+
+// connect applies the transactions of a block to the cached entries, flushing them when the cache is over budget
+func (c *utxoCache) connect(db Store, block *Block) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var spent []utxoUndoEntry
+	err := db.View(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		spent = connectBlock(block, func(key []byte) *UTXOEntry {
+			if cached, ok := c.entries[string(key)]; ok {
+				return cached.entry
+			}
+
+			return getUTXOEntry(b, key)
+		}, c.put)
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	c.undo = append(c.undo, utxoUndo{block.Height, block.Hash, spent})
+	for _, undo := range spent {
+		c.size += entrySize(undo.Key, &undo.Entry)
+	}
+	c.best = block.Hash
+
+	if c.size > c.budget {
+		c.flush(db)
+	}
+}
+
+// put changes the output under key, a nil entry spends it
+func (c *utxoCache) put(key []byte, entry *UTXOEntry) {
+	cached, ok := c.entries[string(key)]
+	if ok {
+		c.size -= entrySize(key, cached.entry)
+	}
+
+	if entry == nil && ok && cached.fresh {
+		delete(c.entries, string(key))
+		return
+	}
+
+	// Outputs are created once, those not cached yet aren't in the bucket either
+	c.entries[string(key)] = &utxoCacheEntry{entry, entry != nil && (!ok || cached.fresh)}
+	c.size += entrySize(key, entry)
+}
+
+// flush writes the changed outputs and the best block in a single transaction and empties the cache
+func (c *utxoCache) flush(db Store) {
+	if c.best == nil {
+		return
+	}
+
+	err := db.Update(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		for key, cached := range c.entries {
+			var err error
+			if cached.entry == nil {
+				err = b.Delete([]byte(key))
+			} else {
+				err = b.Put([]byte(key), cached.entry.Serialize())
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, undo := range c.undo {
+			err := putUTXOUndo(tx, undo)
+			if err != nil {
+				return err
+			}
+		}
+
+		return putUTXOTip(tx, c.best)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	c.reset()
+}
+
+// reset drops the cached entries without writing them, the chainstate bucket is what's left
+func (c *utxoCache) reset() {
+	c.entries = make(map[string]*utxoCacheEntry)
+	c.size = 0
+	c.best = nil
+	c.undo = nil
+}
+
+// sortedKeys returns the keys of the cached entries in the order of the chainstate bucket
+func (c *utxoCache) sortedKeys() [][]byte {
+	keys := make([][]byte, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, []byte(key))
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+
+	return keys
+}
+
+// entrySize estimates the memory a cache entry takes
+func entrySize(key []byte, entry *UTXOEntry) int64 {
+	size := int64(utxoCacheEntryOverhead + len(key))
+	if entry != nil {
+		size += int64(len(entry.Output.PubKeyHash) + len(entry.Output.Data))
+	}
+
+	return size
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUTXOCache(t *testing.T) {
+	sender := NewWallet()
+	bc := newTestBlockchain(t, sender.Address())
+	UTXOSet{bc}.Reindex()
+	genesis := bc.tip
+
+	bc.utxoCache = newUTXOCache(1 << 20)
+	cached := UTXOSet{bc}
+
+	selector, err := GetCoinSelector(defaultCoinSelection)
+	assert.Nil(t, err)
+	receiver := NewWallet()
+	tx := NewUTXOTransaction([]*Wallet{sender}, []Recipient{{receiver.Address(), 4}}, sender.Address(), selector, 0, sequenceFinal, &cached)
+	block := bc.MineBlock([]*Transaction{NewCoinbaseTX(sender.Address(), ""), tx})
+	cached.Update(block)
+
+	// Lookups see the block, the chainstate bucket doesn't yet
+	assert.Equal(t, 2, cached.CountTransactions())
+	assert.Equal(t, block.Hash, cached.syncedHash())
+	stored := UTXOSet{&Blockchain{tip: bc.tip, db: bc.db, blocks: bc.blocks}}
+	assert.Equal(t, 1, stored.CountTransactions())
+	assert.Equal(t, genesis, stored.syncedHash())
+	balance, _ := cached.FindSpendableOutputs(HashPubKey(receiver.PublicKey), 4)
+	assert.Equal(t, 4, balance)
+
+	// A node that stops without flushing syncs forward from the block the chainstate bucket is up to date with
+	assert.Nil(t, stored.Sync())
+	_, err = stored.Blockchain.VerifyChain(maxVerifyLevel)
+	assert.Nil(t, err)
+
+	// Going over the budget writes the changes along with the block they're up to date with
+	bc.utxoCache.budget = 0
+	next := bc.MineBlock([]*Transaction{NewCoinbaseTX(sender.Address(), "")})
+	cached.Update(next)
+	assert.Empty(t, bc.utxoCache.entries)
+	assert.Equal(t, 3, stored.CountTransactions())
+	assert.Equal(t, next.Hash, stored.syncedHash())
+
+	_, err = bc.VerifyChain(maxVerifyLevel)
+	assert.Nil(t, err)
+}
@@ -1,89 +1,212 @@
 package main
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
-
-	"github.com/boltdb/bolt"
 )
 
 const utxoBucket = "chainstate"
 
+// utxoTipBucket keeps under "l" the block the UTXO set is up to date with
+const utxoTipBucket = "chainstatetip"
+
+// utxoReindexKey is set in utxoTipBucket while the UTXO set is rebuilt, "l" is then the block the reindex got to
+var utxoReindexKey = []byte("r")
+
+// utxoReindexBatch is the number of blocks a reindex writes to the UTXO set in one transaction
+var utxoReindexBatch = 500
+
+// outpointKeyLength is the length of chainstate keys, a transaction ID followed by an output index
+const outpointKeyLength = 32 + 4
+
 // UTXOSet represents UTXO set
 type UTXOSet struct {
 	Blockchain *Blockchain
 }
 
+// UTXOEntry is an unspent output in the chainstate bucket, with the height of the block that created it
+type UTXOEntry struct {
+	Output   TXOutput
+	Height   int
+	Coinbase bool
+}
+
+// Serialize serializes UTXOEntry
+func (e UTXOEntry) Serialize() []byte {
+	var buff bytes.Buffer
+
+	enc := gob.NewEncoder(&buff)
+	err := enc.Encode(e)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return buff.Bytes()
+}
+
+// DeserializeUTXOEntry deserializes UTXOEntry
+func DeserializeUTXOEntry(data []byte) UTXOEntry {
+	var entry UTXOEntry
+
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	err := dec.Decode(&entry)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return entry
+}
+
+// outpointKey returns the chainstate key of output vout of transaction txID, the index goes in big endian
+// so the outputs of a transaction are next to each other and in order
+func outpointKey(txID []byte, vout int) []byte {
+	key := make([]byte, len(txID)+4)
+	copy(key, txID)
+	binary.BigEndian.PutUint32(key[len(txID):], uint32(vout))
+
+	return key
+}
+
+// splitOutpointKey returns the transaction ID and output index of a chainstate key
+func splitOutpointKey(key []byte) ([]byte, int) {
+	txID := make([]byte, len(key)-4)
+	copy(txID, key)
+
+	return txID, int(binary.BigEndian.Uint32(key[len(txID):]))
+}
+
 // FindSpendableOutputs finds and returns unspent outputs to reference in inputs
 func (u UTXOSet) FindSpendableOutputs(pubkeyHash []byte, amount int) (int, map[string][]int) {
 	unspentOutputs := make(map[string][]int)
 	accumulated := 0
-	db := u.Blockchain.db
 
-	err := db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(utxoBucket))
-		c := b.Cursor()
+	u.forEach(func(txID []byte, vout int, entry UTXOEntry) {
+		if entry.Output.IsLockedWithKey(pubkeyHash) && accumulated < amount {
+			accumulated += entry.Output.Value
+			key := hex.EncodeToString(txID)
+			unspentOutputs[key] = append(unspentOutputs[key], vout)
+		}
+	})
 
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			txID := hex.EncodeToString(k)
-			outs := DeserializeOutputs(v)
+	return accumulated, unspentOutputs
+}
 
-			for outIdx, out := range outs.Outputs {
-				if out.IsLockedWithKey(pubkeyHash) && accumulated < amount {
-					accumulated += out.Value
-					unspentOutputs[txID] = append(unspentOutputs[txID], outIdx)
-				}
-			}
-		}
+// FindUnspentOutputs returns all unspent outputs locked with pubKeyHash
+func (u UTXOSet) FindUnspentOutputs(pubKeyHash []byte) []UnspentOutput {
+	var unspentOutputs []UnspentOutput
 
-		return nil
+	u.forEach(func(txID []byte, vout int, entry UTXOEntry) {
+		if entry.Output.IsLockedWithKey(pubKeyHash) {
+			unspentOutputs = append(unspentOutputs, UnspentOutput{txID, vout, entry.Output})
+		}
 	})
-	if err != nil {
-		log.Panic(err)
-	}
 
-	return accumulated, unspentOutputs
+	return unspentOutputs
 }
 
 // FindUTXO finds UTXO for a public key hash
 func (u UTXOSet) FindUTXO(pubKeyHash []byte) []TXOutput {
 	var UTXOs []TXOutput
-	db := u.Blockchain.db
 
-	err := db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(utxoBucket))
-		c := b.Cursor()
+	u.forEach(func(txID []byte, vout int, entry UTXOEntry) {
+		if entry.Output.IsLockedWithKey(pubKeyHash) {
+			UTXOs = append(UTXOs, entry.Output)
+		}
+	})
 
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			outs := DeserializeOutputs(v)
+	return UTXOs
+}
 
-			for _, out := range outs.Outputs {
-				if out.IsLockedWithKey(pubKeyHash) {
-					UTXOs = append(UTXOs, out)
-				}
+// entry returns the unspent output under a chainstate key, the UTXO cache taking the place of the bucket
+func (u UTXOSet) entry(key []byte) (UTXOEntry, bool) {
+	if cache := u.Blockchain.utxoCache; cache != nil {
+		cache.mu.Lock()
+		cached, ok := cache.entries[string(key)]
+		cache.mu.Unlock()
+		if ok {
+			if cached.entry == nil {
+				return UTXOEntry{}, false
 			}
+			return *cached.entry, true
 		}
+	}
+
+	var entry *UTXOEntry
+	err := u.Blockchain.db.View(func(tx StoreTx) error {
+		entry = getUTXOEntry(tx.Bucket([]byte(utxoBucket)), key)
 
 		return nil
 	})
 	if err != nil {
 		log.Panic(err)
 	}
+	if entry == nil {
+		return UTXOEntry{}, false
+	}
 
-	return UTXOs
+	return *entry, true
 }
 
 // CountTransactions returns the number of transactions in the UTXO set
 func (u UTXOSet) CountTransactions() int {
-	db := u.Blockchain.db
 	counter := 0
+	var last []byte
+
+	// The outputs of a transaction are next to each other
+	u.forEach(func(txID []byte, vout int, entry UTXOEntry) {
+		if bytes.Compare(txID, last) != 0 {
+			counter++
+			last = txID
+		}
+	})
 
-	err := db.View(func(tx *bolt.Tx) error {
+	return counter
+}
+
+// forEach calls fn with every unspent output in the order of the chainstate bucket, the entries in the
+// UTXO cache take the place of the bucket's. txID is a copy fn may keep
+func (u UTXOSet) forEach(fn func(txID []byte, vout int, entry UTXOEntry)) {
+	var keys [][]byte
+	cache := u.Blockchain.utxoCache
+	if cache != nil {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		keys = cache.sortedKeys()
+	}
+
+	cached := func(key []byte) {
+		entry := cache.entries[string(key)].entry
+		if entry != nil {
+			txID, vout := splitOutpointKey(key)
+			fn(txID, vout, *entry)
+		}
+	}
+
+	err := u.Blockchain.db.View(func(tx StoreTx) error {
 		b := tx.Bucket([]byte(utxoBucket))
 		c := b.Cursor()
 
-		for k, _ := c.First(); k != nil; k, _ = c.Next() {
-			counter++
+		i := 0
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			for ; i < len(keys) && bytes.Compare(keys[i], k) < 0; i++ {
+				cached(keys[i])
+			}
+			if i < len(keys) && bytes.Equal(keys[i], k) {
+				cached(keys[i])
+				i++
+				continue
+			}
+
+			txID, vout := splitOutpointKey(k)
+			fn(txID, vout, DeserializeUTXOEntry(v))
+		}
+		for ; i < len(keys); i++ {
+			cached(keys[i])
 		}
 
 		return nil
@@ -91,25 +214,21 @@ func (u UTXOSet) CountTransactions() int {
 	if err != nil {
 		log.Panic(err)
 	}
-
-	return counter
 }
 
-// Reindex rebuilds the UTXO set
-func (u UTXOSet) Reindex() {
-	db := u.Blockchain.db
-	bucketName := []byte(utxoBucket)
+// migrate rebuilds a UTXO set stored as the remaining outputs of each transaction, which lost the indices of partly spent
+// transactions' outputs, in entries per output
+func (u UTXOSet) migrate() {
+	old := false
 
-	err := db.Update(func(tx *bolt.Tx) error {
-		err := tx.DeleteBucket(bucketName)
-		if err != nil && err != bolt.ErrBucketNotFound {
-			log.Panic(err)
+	err := u.Blockchain.db.View(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		if b == nil {
+			return nil
 		}
 
-		_, err = tx.CreateBucket(bucketName)
-		if err != nil {
-			log.Panic(err)
-		}
+		k, _ := b.Cursor().First()
+		old = k != nil && len(k) != outpointKeyLength
 
 		return nil
 	})
@@ -117,77 +236,281 @@ func (u UTXOSet) Reindex() {
 		log.Panic(err)
 	}
 
-	UTXO := u.Blockchain.FindUTXO()
+	if old {
+		fmt.Println("Rebuilding the UTXO set with an entry per output")
+		u.Blockchain.requireAllBlocks()
+		u.Reindex()
+	}
+}
 
-	err = db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(bucketName)
+// Reindex rebuilds the UTXO set
+func (u UTXOSet) Reindex() {
+	u.ReindexWithProgress(nil)
+}
 
-		for txID, outs := range UTXO {
-			key, err := hex.DecodeString(txID)
+// ReindexWithProgress rebuilds the UTXO set from the genesis block forward, writing utxoReindexBatch blocks
+// per transaction along with the block it got to, so an interrupted reindex resumes from there.
+// progress is called after every batch with the height reached and the best height
+func (u UTXOSet) ReindexWithProgress(progress func(height, bestHeight int)) {
+	bc := u.Blockchain
+	u.dropCache()
+
+	height := 0
+	if u.IsReindexing() {
+		if synced := u.syncedHash(); synced != nil {
+			header, err := bc.GetBlockHeader(synced)
 			if err != nil {
 				log.Panic(err)
 			}
+			height = header.Height + 1
 
-			err = b.Put(key, outs.Serialize())
+			// The chain may have switched to another branch since, then the reindex starts over
+			hash, err := HeightIndex{bc}.Hash(header.Height)
+			if err != nil || bytes.Compare(hash, synced) != 0 {
+				height = 0
+			}
+		}
+	}
+	if height == 0 {
+		u.startReindex()
+	}
+
+	best := bc.GetBestHeight()
+	for height <= best {
+		var blocks []*Block
+		for ; len(blocks) < utxoReindexBatch && height <= best; height++ {
+			block, err := bc.GetBlockByHeight(height)
 			if err != nil {
 				log.Panic(err)
 			}
+			blocks = append(blocks, &block)
+		}
+
+		err := bc.db.Update(func(tx StoreTx) error {
+			for _, block := range blocks {
+				err := u.update(tx, block)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			log.Panic(err)
+		}
+
+		if progress != nil {
+			progress(height-1, best)
 		}
+	}
+
+	err := bc.db.Update(func(tx StoreTx) error {
+		return tx.Bucket([]byte(utxoTipBucket)).Delete(utxoReindexKey)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// dropCache discards the changes in the UTXO cache, for when the chainstate bucket is rebuilt anyway
+func (u UTXOSet) dropCache() {
+	cache := u.Blockchain.utxoCache
+	if cache == nil {
+		return
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.reset()
+}
+
+// IsReindexing tells if a reindex of the UTXO set was interrupted, the UTXO set is incomplete until it's resumed
+func (u UTXOSet) IsReindexing() bool {
+	reindexing := false
+
+	err := u.Blockchain.db.View(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(utxoTipBucket))
+		reindexing = b != nil && b.Get(utxoReindexKey) != nil
 
 		return nil
 	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return reindexing
+}
+
+// startReindex empties the UTXO set and marks it as being reindexed, in a single transaction
+func (u UTXOSet) startReindex() {
+	err := u.Blockchain.db.Update(func(tx StoreTx) error {
+		err := tx.DeleteBucket([]byte(utxoBucket))
+		if err != nil && err != errBucketNotFound {
+			return err
+		}
+
+		_, err = tx.CreateBucket([]byte(utxoBucket))
+		if err != nil {
+			return err
+		}
+
+		err = tx.DeleteBucket([]byte(utxoUndoBucket))
+		if err != nil && err != errBucketNotFound {
+			return err
+		}
+
+		b, err := tx.CreateBucketIfNotExists([]byte(utxoTipBucket))
+		if err != nil {
+			return err
+		}
+
+		err = b.Delete([]byte("l"))
+		if err != nil {
+			return err
+		}
+
+		return b.Put(utxoReindexKey, []byte{1})
+	})
+	if err != nil {
+		log.Panic(err)
+	}
 }
 
 // Update updates the UTXO set with transactions from the Block
 // The Block is considered to be the tip of a blockchain
 func (u UTXOSet) Update(block *Block) {
-	db := u.Blockchain.db
+	if cache := u.Blockchain.utxoCache; cache != nil {
+		cache.connect(u.Blockchain.db, block)
+		return
+	}
 
-	err := db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(utxoBucket))
+	err := u.Blockchain.db.Update(func(tx StoreTx) error {
+		return u.update(tx, block)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// update applies the transactions of a block to the UTXO set in tx
+func (u UTXOSet) update(tx StoreTx, block *Block) error {
+	b := tx.Bucket([]byte(utxoBucket))
+
+	spent := connectBlock(block, func(key []byte) *UTXOEntry {
+		return getUTXOEntry(b, key)
+	}, func(key []byte, entry *UTXOEntry) {
+		var err error
+		if entry == nil {
+			err = b.Delete(key)
+		} else {
+			err = b.Put(key, entry.Serialize())
+		}
+		if err != nil {
+			log.Panic(err)
+		}
+	})
+
+	err := putUTXOUndo(tx, utxoUndo{block.Height, block.Hash, spent})
+	if err != nil {
+		return err
+	}
 
-		for _, tx := range block.Transactions {
-			if tx.IsCoinbase() == false {
-				for _, vin := range tx.Vin {
-					updatedOuts := TXOutputs{}
-					outsBytes := b.Get(vin.Txid)
-					outs := DeserializeOutputs(outsBytes)
-
-					for outIdx, out := range outs.Outputs {
-						if outIdx != vin.Vout {
-							updatedOuts.Outputs = append(updatedOuts.Outputs, out)
-						}
-					}
-
-					if len(updatedOuts.Outputs) == 0 {
-						err := b.Delete(vin.Txid)
-						if err != nil {
-							log.Panic(err)
-						}
-					} else {
-						err := b.Put(vin.Txid, updatedOuts.Serialize())
-						if err != nil {
-							log.Panic(err)
-						}
-					}
+	return putUTXOTip(tx, block.Hash)
+}
 
+// connectBlock removes the outputs the transactions of a block spend and adds the new ones,
+// put is called with the chainstate key of each and a nil entry for spent outputs.
+// It returns the spent outputs as get finds them before they're spent, for the undo record of the block
+func connectBlock(block *Block, get func(key []byte) *UTXOEntry, put func(key []byte, entry *UTXOEntry)) []utxoUndoEntry {
+	var spent []utxoUndoEntry
+
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() == false {
+			for _, vin := range tx.Vin {
+				key := outpointKey(vin.Txid, vin.Vout)
+				if entry := get(key); entry != nil {
+					spent = append(spent, utxoUndoEntry{key, *entry})
 				}
+				put(key, nil)
 			}
+		}
 
-			newOutputs := TXOutputs{}
-			for _, out := range tx.Vout {
-				newOutputs.Outputs = append(newOutputs.Outputs, out)
+		for outIdx, out := range tx.Vout {
+			// Data outputs can never be spent, so they never enter the UTXO set
+			if out.IsUnspendable() {
+				continue
 			}
 
-			err := b.Put(tx.ID, newOutputs.Serialize())
-			if err != nil {
-				log.Panic(err)
-			}
+			put(outpointKey(tx.ID, outIdx), &UTXOEntry{out, block.Height, tx.IsCoinbase()})
 		}
+	}
 
+	return spent
+}
+
+// getUTXOEntry returns the entry of the chainstate bucket b under key, nil when there is none
+func getUTXOEntry(b StoreBucket, key []byte) *UTXOEntry {
+	data := b.Get(key)
+	if data == nil {
 		return nil
-	})
+	}
+	entry := DeserializeUTXOEntry(data)
+
+	return &entry
+}
+
+// Sync brings the UTXO set up to the tip of the chain. Blocks of a branch the chain left are disconnected
+// with their undo records, which are only kept for the last minBlocksToKeep blocks: deeper reorgs take a Reindex
+func (u UTXOSet) Sync() error {
+	bc := u.Blockchain
+	synced := u.syncedHash()
+	if synced == nil {
+		return errors.New("UTXO set doesn't know its best block, it has to be reindexed")
+	}
+
+	// Headers are enough to find the blocks to disconnect and connect, only those need their bodies
+	disconnect, connect := bc.forkBranches(synced, bc.tip)
+	for _, hash := range disconnect {
+		block, err := bc.GetBlock(hash)
+		if err != nil {
+			return err
+		}
+
+		err = u.disconnect(&block)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, hash := range connect {
+		block, err := bc.GetBlock(hash)
+		if err != nil {
+			return err
+		}
+		u.Update(&block)
+	}
+
+	return nil
+}
+
+func (u UTXOSet) syncedHash() []byte {
+	if cache := u.Blockchain.utxoCache; cache != nil {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		if cache.best != nil {
+			return cache.best
+		}
+	}
+
+	return indexTip(u.Blockchain.db, utxoTipBucket)
+}
+
+func putUTXOTip(tx StoreTx, hash []byte) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(utxoTipBucket))
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
+
+	return b.Put([]byte("l"), hash)
 }
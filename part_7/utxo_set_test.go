@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUTXOSetReindexResumes(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet.Address())
+	for i := 0; i < 4; i++ {
+		bc.MineBlock([]*Transaction{NewCoinbaseTX(wallet.Address(), "")})
+	}
+
+	defer func(batch int) { utxoReindexBatch = batch }(utxoReindexBatch)
+	utxoReindexBatch = 2
+
+	UTXOSet := UTXOSet{bc}
+	var heights []int
+	assert.Panics(t, func() {
+		UTXOSet.ReindexWithProgress(func(height, bestHeight int) {
+			heights = append(heights, height)
+			if height == 3 {
+				panic("interrupted")
+			}
+		})
+	})
+	assert.Equal(t, []int{1, 3}, heights)
+	assert.True(t, UTXOSet.IsReindexing())
+	assert.Equal(t, 4, UTXOSet.CountTransactions(), "Batches written before the interruption are kept")
+
+	heights = nil
+	UTXOSet.ReindexWithProgress(func(height, bestHeight int) {
+		heights = append(heights, height)
+	})
+	assert.Equal(t, []int{4}, heights, "The reindex resumes after the last batch")
+	assert.False(t, UTXOSet.IsReindexing())
+	assert.Equal(t, 5, UTXOSet.CountTransactions())
+
+	_, err := bc.VerifyChain(maxVerifyLevel)
+	assert.Nil(t, err)
+}
+
+func TestUTXOSetKeepsOutputIndices(t *testing.T) {
+	for _, cached := range []bool{false, true} {
+		sender := NewWallet()
+		bc := newTestBlockchain(t, sender.Address())
+		UTXOSet := UTXOSet{bc}
+		UTXOSet.Reindex()
+		if cached {
+			bc.utxoCache = newUTXOCache(1 << 20)
+		}
+
+		selector, err := GetCoinSelector(defaultCoinSelection)
+		assert.Nil(t, err)
+		receiver := NewWallet()
+		payment := NewUTXOTransaction([]*Wallet{sender}, []Recipient{{receiver.Address(), 4}}, sender.Address(), selector, 0, sequenceFinal, &UTXOSet)
+		block := bc.MineBlock([]*Transaction{NewCoinbaseTX(sender.Address(), ""), payment})
+		UTXOSet.Update(block)
+
+		// The receiver spends output 0, the change stays output 1
+		spend := NewUTXOTransaction([]*Wallet{receiver}, []Recipient{{NewWallet().Address(), 4}}, receiver.Address(), selector, 0, sequenceFinal, &UTXOSet)
+		block = bc.MineBlock([]*Transaction{NewCoinbaseTX(sender.Address(), ""), spend})
+		UTXOSet.Update(block)
+
+		senderHash := HashPubKey(sender.PublicKey)
+		_, outputs := UTXOSet.FindSpendableOutputs(senderHash, 1000)
+		assert.Equal(t, []int{1}, outputs[hex.EncodeToString(payment.ID)], "Cached: %v", cached)
+		for _, utxo := range UTXOSet.FindUnspentOutputs(senderHash) {
+			if bytes.Equal(utxo.TxID, payment.ID) {
+				assert.Equal(t, 6, utxo.Output.Value)
+			}
+		}
+
+		UTXOSet.Flush()
+		snapshot, err := UTXOSet.Snapshot()
+		assert.Nil(t, err)
+		for _, utxo := range snapshot.UTXO {
+			txID, _ := splitOutpointKey(utxo.Key)
+			assert.Equal(t, bytes.Equal(txID, payment.ID), utxo.Entry.Height == 1 && !utxo.Entry.Coinbase)
+		}
+
+		_, err = bc.VerifyChain(maxVerifyLevel)
+		assert.Nil(t, err)
+	}
+}
+
+func TestUTXOSetReorg(t *testing.T) {
+	for _, cached := range []bool{false, true} {
+		sender := NewWallet()
+		bc := newTestBlockchain(t, sender.Address())
+		genesis := bc.tip
+		UTXOSet := UTXOSet{bc}
+		UTXOSet.Reindex()
+		if cached {
+			bc.utxoCache = newUTXOCache(1 << 20)
+		}
+
+		selector, _ := GetCoinSelector(defaultCoinSelection)
+		payment := NewUTXOTransaction([]*Wallet{sender}, []Recipient{{NewWallet().Address(), 4}}, sender.Address(), selector, 0, sequenceFinal, &UTXOSet)
+		UTXOSet.Update(bc.MineBlock([]*Transaction{NewCoinbaseTX(sender.Address(), ""), payment}))
+
+		// A longer branch from the genesis block takes over, the payment's block is disconnected with its undo record
+		other := NewWallet()
+		side := NewBlock([]*Transaction{NewCoinbaseTX(other.Address(), "side")}, genesis, 1)
+		bc.AddBlock(side)
+		sideTip := NewBlock([]*Transaction{NewCoinbaseTX(other.Address(), "")}, side.Hash, 2)
+		bc.AddBlock(sideTip)
+		assert.Nil(t, UTXOSet.Sync(), "Cached: %v", cached)
+		assert.Equal(t, sideTip.Hash, UTXOSet.syncedHash())
+
+		balance, _ := UTXOSet.FindSpendableOutputs(HashPubKey(sender.PublicKey), 1000)
+		assert.Equal(t, subsidy, balance, "The output the payment spent is back")
+
+		synced := make(map[string]UTXOEntry)
+		UTXOSet.forEach(func(txID []byte, vout int, entry UTXOEntry) {
+			synced[string(outpointKey(txID, vout))] = entry
+		})
+		UTXOSet.Reindex()
+		reindexed := make(map[string]UTXOEntry)
+		UTXOSet.forEach(func(txID []byte, vout int, entry UTXOEntry) {
+			reindexed[string(outpointKey(txID, vout))] = entry
+		})
+		assert.Equal(t, reindexed, synced)
+	}
+}
+
+func TestUTXOUndoIsTrimmed(t *testing.T) {
+	db := newMemoryStore()
+	defer db.Close()
+
+	for height := 0; height <= minBlocksToKeep+1; height++ {
+		err := db.Update(func(tx StoreTx) error {
+			return putUTXOUndo(tx, utxoUndo{height, []byte{byte(height)}, nil})
+		})
+		assert.Nil(t, err)
+	}
+
+	var heights []int
+	err := db.View(func(tx StoreTx) error {
+		return tx.Bucket([]byte(utxoUndoBucket)).ForEach(func(k, v []byte) error {
+			heights = append(heights, int(binary.BigEndian.Uint32(k)))
+			return nil
+		})
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, minBlocksToKeep, len(heights), "Records of the last minBlocksToKeep blocks are kept")
+	assert.Equal(t, 2, heights[0])
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+)
+
+// snapshotKey holds in the block index the UTXO snapshot the node started from, until the blocks below it are checked
+var snapshotKey = []byte("s")
+
+// utxoSnapshot is the UTXO set at a block, with the headers up to it, so a node can start from there
+type utxoSnapshot struct {
+	Tip    []byte
+	Height int
+	// Headers go from the genesis block up to Tip
+	Headers []BlockHeader
+	UTXO    []snapshotUTXO
+	// Hash commits to UTXO, see utxoSetHash
+	Hash []byte
+}
+
+// snapshotUTXO is an entry of the chainstate bucket
+type snapshotUTXO struct {
+	Key   []byte
+	Entry UTXOEntry
+}
+
+// snapshotCheck is what a node started from a snapshot remembers to check it later against the blocks below it
+type snapshotCheck struct {
+	Tip    []byte
+	Height int
+	Hash   []byte
+}
+
+// utxoSetHash hashes the entries of a UTXO set sorted by key
+func utxoSetHash(utxos []snapshotUTXO) []byte {
+	hash := sha256.New()
+	for _, utxo := range utxos {
+		hash.Write(utxo.Key)
+		hash.Write(utxo.Entry.Serialize())
+	}
+
+	return hash.Sum(nil)
+}
+
+// sortedUTXO turns the result of FindUTXO into snapshot entries sorted by key
+func sortedUTXO(UTXO map[string]UTXOEntry) []snapshotUTXO {
+	var utxos []snapshotUTXO
+	for key, entry := range UTXO {
+		utxos = append(utxos, snapshotUTXO{[]byte(key), entry})
+	}
+
+	sort.Slice(utxos, func(i, j int) bool {
+		return bytes.Compare(utxos[i].Key, utxos[j].Key) < 0
+	})
+
+	return utxos
+}
+
+// Snapshot returns the UTXO set with the headers of the chain up to the block it's up to date with
+func (u UTXOSet) Snapshot() (*utxoSnapshot, error) {
+	snapshot := &utxoSnapshot{}
+	u.Flush()
+
+	err := u.Blockchain.db.View(func(tx StoreTx) error {
+		tip := tx.Bucket([]byte(utxoTipBucket))
+		if tip == nil {
+			return errors.New("UTXO set doesn't know its best block, it has to be reindexed")
+		}
+		snapshot.Tip = append(snapshot.Tip, tip.Get([]byte("l"))...)
+
+		index := tx.Bucket([]byte(blockIndexBucket))
+		for hash := snapshot.Tip; len(hash) > 0; {
+			header := deserializeBlockIndexEntry(index.Get(hash)).Header
+			snapshot.Headers = append([]BlockHeader{header}, snapshot.Headers...)
+			hash = header.PrevBlockHash
+		}
+		snapshot.Height = len(snapshot.Headers) - 1
+
+		// Buckets are sorted by key, the hash needs no further sorting
+		return tx.Bucket([]byte(utxoBucket)).ForEach(func(k, v []byte) error {
+			snapshot.UTXO = append(snapshot.UTXO, snapshotUTXO{append([]byte{}, k...), DeserializeUTXOEntry(v)})
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot.Hash = utxoSetHash(snapshot.UTXO)
+
+	return snapshot, nil
+}
+
+// Write saves the snapshot to a file
+func (s *utxoSnapshot) Write(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = gob.NewEncoder(file).Encode(s)
+	if err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// readUTXOSnapshot reads a snapshot file and checks that it's consistent
+func readUTXOSnapshot(path string) (*utxoSnapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var snapshot utxoSnapshot
+	err = gob.NewDecoder(file).Decode(&snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	return &snapshot, snapshot.verify()
+}
+
+// verify checks that the headers form a chain with valid proofs of work up to the tip and that the UTXO set matches its hash
+func (s *utxoSnapshot) verify() error {
+	if s.Height < 0 || len(s.Headers) != s.Height+1 {
+		return errors.New("UTXO snapshot doesn't have a header for every height")
+	}
+
+	for i, header := range s.Headers {
+		if header.Height != i {
+			return fmt.Errorf("UTXO snapshot header %x has height %d at %d", header.Hash, header.Height, i)
+		}
+		if i > 0 && bytes.Compare(header.PrevBlockHash, s.Headers[i-1].Hash) != 0 {
+			return fmt.Errorf("UTXO snapshot header %x doesn't follow the one before it", header.Hash)
+		}
+
+		pow := NewHeaderProofOfWork(header)
+		powHash := sha256.Sum256(pow.prepareData(header.Nonce))
+		if bytes.Compare(powHash[:], header.Hash) != 0 || !pow.Validate() {
+			return fmt.Errorf("UTXO snapshot header %x doesn't have a valid proof of work", header.Hash)
+		}
+	}
+
+	if bytes.Compare(s.Headers[s.Height].Hash, s.Tip) != 0 {
+		return errors.New("UTXO snapshot headers don't end at its tip")
+	}
+
+	for i, utxo := range s.UTXO {
+		if len(utxo.Key) != outpointKeyLength {
+			return fmt.Errorf("UTXO snapshot entry %x isn't an outpoint", utxo.Key)
+		}
+		if i > 0 && bytes.Compare(s.UTXO[i-1].Key, utxo.Key) >= 0 {
+			return errors.New("UTXO snapshot entries aren't sorted")
+		}
+	}
+
+	if bytes.Compare(utxoSetHash(s.UTXO), s.Hash) != 0 {
+		return errors.New("UTXO snapshot doesn't match its hash")
+	}
+
+	return nil
+}
+
+// loadUTXOSnapshot sets up an empty store as a node at the tip of the snapshot. It has the headers but none
+// of the blocks, which makes it a pruned node until the blocks below the snapshot are downloaded and checked
+func loadUTXOSnapshot(db Store, s *utxoSnapshot) error {
+	return db.Update(func(tx StoreTx) error {
+		index, err := tx.CreateBucket([]byte(blockIndexBucket))
+		if err != nil {
+			return err
+		}
+		heights, err := tx.CreateBucket([]byte(heightIndexBucket))
+		if err != nil {
+			return err
+		}
+
+		for _, header := range s.Headers {
+			err = index.Put(header.Hash, blockIndexEntry{blockPos{}, header, 0}.serialize())
+			if err != nil {
+				return err
+			}
+
+			err = heights.Put(heightKey(header.Height), header.Hash)
+			if err != nil {
+				return err
+			}
+		}
+
+		check := snapshotCheck{s.Tip, s.Height, s.Hash}
+		for key, value := range map[string][]byte{"l": s.Tip, "n": []byte(activeNetwork.Name), "p": heightKey(s.Height + 1), "s": gobEncode(check)} {
+			err = index.Put([]byte(key), value)
+			if err != nil {
+				return err
+			}
+		}
+
+		chainstate, err := tx.CreateBucket([]byte(utxoBucket))
+		if err != nil {
+			return err
+		}
+		for _, utxo := range s.UTXO {
+			err = chainstate.Put(utxo.Key, utxo.Entry.Serialize())
+			if err != nil {
+				return err
+			}
+		}
+
+		// The indexes start at the snapshot, they can't be built without the blocks below it
+		for _, bucket := range []string{utxoTipBucket, heightIndexBucket, txIndexBucket, walletTxBucket} {
+			b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+			if err != nil {
+				return err
+			}
+
+			err = b.Put([]byte("l"), s.Tip)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// snapshotCheck returns the snapshot the node started from, while it's not checked yet
+func (bc *Blockchain) snapshotCheck() (snapshotCheck, bool) {
+	var check snapshotCheck
+	found := false
+
+	err := bc.db.View(func(tx StoreTx) error {
+		data := tx.Bucket([]byte(blockIndexBucket)).Get(snapshotKey)
+		if data == nil {
+			return nil
+		}
+		found = true
+
+		return gob.NewDecoder(bytes.NewReader(data)).Decode(&check)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return check, found
+}
+
+// SnapshotPending tells if the node started from a UTXO snapshot that isn't checked against the blocks yet
+func (bc *Blockchain) SnapshotPending() bool {
+	_, pending := bc.snapshotCheck()
+
+	return pending
+}
+
+// ValidateSnapshot recomputes the UTXO set at the snapshot the node started from, once all the blocks below it
+// are downloaded, and compares it to the hash of the snapshot. It returns false while blocks are missing.
+// When the snapshot doesn't match, the UTXO set is rebuilt from the blocks
+func (bc *Blockchain) ValidateSnapshot() (bool, error) {
+	check, pending := bc.snapshotCheck()
+	if !pending {
+		return false, nil
+	}
+
+	for hash := check.Tip; len(hash) > 0; {
+		entry, err := bc.getBlockIndexEntry(hash)
+		if err != nil {
+			return false, err
+		}
+		if entry.Status&blockHaveData == 0 {
+			return false, nil
+		}
+		hash = entry.Header.PrevBlockHash
+	}
+
+	valid := bytes.Compare(utxoSetHash(sortedUTXO(bc.findUTXOAt(check.Tip))), check.Hash) == 0
+
+	// The transaction index, and the UTXO set of a wrong snapshot, are rebuilt before the markers go.
+	// A node stopped in between checks the snapshot again instead of passing for a full node
+	TxIndex{bc}.Reindex()
+	if !valid {
+		UTXOSet{bc}.Reindex()
+	}
+
+	// All the blocks are there, the node is a full node again
+	err := bc.db.Update(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(blockIndexBucket))
+
+		err := b.Delete(snapshotKey)
+		if err != nil {
+			return err
+		}
+
+		return b.Delete(pruneHeightKey)
+	})
+	if err != nil {
+		return true, err
+	}
+
+	if !valid {
+		return true, fmt.Errorf("UTXO snapshot at height %d doesn't match the blocks, the UTXO set is rebuilt from them", check.Height)
+	}
+
+	return true, nil
+}
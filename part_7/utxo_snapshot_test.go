@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUTXOSnapshot(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet.Address())
+
+	bc.MineBlock([]*Transaction{NewCoinbaseTX(wallet.Address(), "")})
+	bc.MineBlock([]*Transaction{NewCoinbaseTX(wallet.Address(), "")})
+	UTXOSet{bc}.Reindex()
+
+	snapshot, err := UTXOSet{bc}.Snapshot()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, snapshot.Height)
+	assert.Len(t, snapshot.UTXO, 3)
+
+	path := filepath.Join(t.TempDir(), "utxo.dat")
+	assert.Nil(t, snapshot.Write(path))
+	read, err := readUTXOSnapshot(path)
+	assert.Nil(t, err)
+	assert.Equal(t, snapshot.Hash, read.Hash)
+
+	read.UTXO = read.UTXO[1:]
+	assert.NotNil(t, read.verify(), "Snapshots must match their hash")
+	assert.NotNil(t, (&utxoSnapshot{Height: -1}).verify(), "Snapshots have at least the genesis header")
+
+	forged := *snapshot
+	forged.Headers = append([]BlockHeader{}, snapshot.Headers...)
+	forged.Headers[2].TxHash = forged.Headers[1].TxHash
+	assert.NotNil(t, forged.verify(), "Headers must carry their proof of work")
+
+	// A new node starts from the snapshot without any blocks
+	db := newMemoryStore()
+	assert.Nil(t, loadUTXOSnapshot(db, snapshot))
+	newBlocks, err := openBlockFiles(t.TempDir())
+	assert.Nil(t, err)
+	node := &Blockchain{tip: snapshot.Tip, db: db, blocks: newBlocks}
+	node.syncIndexes()
+
+	assert.Equal(t, 2, node.GetBestHeight())
+	assert.Equal(t, 3, node.PruneHeight())
+	assert.Equal(t, 3, UTXOSet{node}.CountTransactions())
+	_, err = node.GetBlockByHeight(1)
+	assert.True(t, errors.Is(err, errBlockPruned))
+
+	// Blocks after the snapshot are synced forward
+	next := bc.MineBlock([]*Transaction{NewCoinbaseTX(wallet.Address(), "")})
+	node.AddBlock(next)
+	assert.Nil(t, UTXOSet{node}.Sync())
+	assert.Equal(t, 4, UTXOSet{node}.CountTransactions())
+
+	done, err := node.ValidateSnapshot()
+	assert.Nil(t, err)
+	assert.False(t, done, "Blocks below the snapshot are missing")
+
+	// Blocks arrive from the tip down, like after getblocks
+	for height := 2; height >= 0; height-- {
+		block, err := bc.GetBlockByHeight(height)
+		assert.Nil(t, err)
+		node.AddBlock(&block)
+	}
+
+	done, err = node.ValidateSnapshot()
+	assert.Nil(t, err)
+	assert.True(t, done)
+	assert.False(t, node.SnapshotPending())
+	assert.Equal(t, 0, node.PruneHeight())
+
+	genesis, err := node.GetBlockByHeight(0)
+	assert.Nil(t, err)
+	_, err = node.FindTransaction(genesis.Transactions[0].ID)
+	assert.Nil(t, err, "The transaction index covers the blocks below the snapshot")
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"log"
+)
+
+// utxoUndoBucket keeps the outputs spent by each of the last minBlocksToKeep blocks connected to the UTXO set,
+// so it can switch to another branch without a reindex. Pruned nodes don't have the blocks to reindex
+const utxoUndoBucket = "chainstateundo"
+
+// utxoUndoEntry is an output a block spent, with its chainstate key
+type utxoUndoEntry struct {
+	Key   []byte
+	Entry UTXOEntry
+}
+
+// utxoUndo is the undo record of a block
+type utxoUndo struct {
+	height int
+	hash   []byte
+	spent  []utxoUndoEntry
+}
+
+// utxoUndoKey puts the height first, so records are sorted by height and old ones are found at the start
+func utxoUndoKey(height int, hash []byte) []byte {
+	return append(heightKey(height), hash...)
+}
+
+// putUTXOUndo writes the undo record of a block and deletes those of blocks minBlocksToKeep or more below it
+func putUTXOUndo(tx StoreTx, undo utxoUndo) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(utxoUndoBucket))
+	if err != nil {
+		return err
+	}
+
+	err = b.Put(utxoUndoKey(undo.height, undo.hash), gobEncode(undo.spent))
+	if err != nil {
+		return err
+	}
+
+	if undo.height < minBlocksToKeep {
+		return nil
+	}
+
+	var old [][]byte
+	oldest := heightKey(undo.height - minBlocksToKeep + 1)
+	c := b.Cursor()
+	for k, _ := c.First(); k != nil && bytes.Compare(k[:4], oldest) < 0; k, _ = c.Next() {
+		old = append(old, append([]byte{}, k...))
+	}
+	for _, k := range old {
+		err = b.Delete(k)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// disconnect undoes block, the block the UTXO set is up to date with, from its undo record
+func (u UTXOSet) disconnect(block *Block) error {
+	u.Flush()
+
+	return u.Blockchain.db.Update(func(tx StoreTx) error {
+		key := utxoUndoKey(block.Height, block.Hash)
+		undoBucket := tx.Bucket([]byte(utxoUndoBucket))
+		var data []byte
+		if undoBucket != nil {
+			data = undoBucket.Get(key)
+		}
+		if data == nil {
+			return fmt.Errorf("UTXO set has no undo data for block %x, it has to be reindexed", block.Hash)
+		}
+
+		var spent []utxoUndoEntry
+		err := gob.NewDecoder(bytes.NewReader(data)).Decode(&spent)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		// The spent outputs come back first: some were created by the block itself and go again below
+		b := tx.Bucket([]byte(utxoBucket))
+		for _, undo := range spent {
+			err = b.Put(undo.Key, undo.Entry.Serialize())
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, transaction := range block.Transactions {
+			for outIdx, out := range transaction.Vout {
+				if out.IsUnspendable() {
+					continue
+				}
+
+				err = b.Delete(outpointKey(transaction.ID, outIdx))
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		err = undoBucket.Delete(key)
+		if err != nil {
+			return err
+		}
+
+		return putUTXOTip(tx, block.PrevBlockHash)
+	})
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// maxVerifyLevel is the most thorough level of VerifyChain
+const maxVerifyLevel = 3
+
+// chainVerifier checks the blocks of the main chain in order, remembering what the earlier blocks did
+type chainVerifier struct {
+	bc    *Blockchain
+	level int
+	// seen holds the IDs of the transactions checked so far, spent the outputs they spent
+	seen  map[string]bool
+	spent map[string]bool
+}
+
+// VerifyChain checks the main chain from the genesis block to the tip and returns the number of blocks checked.
+// Level 0 checks the proof of work, hash linkage and heights, level 1 adds the Merkle roots and transaction IDs,
+// level 2 the signatures, lock times, spent outputs and coinbase rules, and level 3 recomputes the UTXO set
+// and compares it to the chainstate bucket. The error points to the first inconsistency
+func (bc *Blockchain) VerifyChain(level int) (int, error) {
+	if height := bc.PruneHeight(); height > 0 && level >= 2 {
+		return 0, fmt.Errorf("Blocks below height %d are pruned, only levels 0 and 1 can be checked", height)
+	}
+
+	var hashes [][]byte
+	for hash := bc.tip; len(hash) > 0; {
+		header, err := bc.GetBlockHeader(hash)
+		if err != nil {
+			return 0, fmt.Errorf("Block %x: %s", hash, err)
+		}
+		hashes = append(hashes, hash)
+		hash = header.PrevBlockHash
+	}
+
+	v := chainVerifier{bc, level, make(map[string]bool), make(map[string]bool)}
+	var prev *BlockHeader
+	for i := len(hashes) - 1; i >= 0; i-- {
+		entry, err := bc.getBlockIndexEntry(hashes[i])
+		if err != nil {
+			return len(hashes) - 1 - i, err
+		}
+
+		reason := v.checkBlock(hashes[i], entry, prev)
+		if reason != "" {
+			return len(hashes) - 1 - i, fmt.Errorf("Block %x at height %d: %s", hashes[i], entry.Header.Height, reason)
+		}
+		prev = &entry.Header
+	}
+
+	if level >= 3 {
+		reason := v.checkUTXOSet()
+		if reason != "" {
+			return len(hashes), fmt.Errorf("Block %x at height %d: %s", bc.tip, prev.Height, reason)
+		}
+	}
+
+	return len(hashes), nil
+}
+
+// checkBlock returns why the block doesn't fit after prev, an empty string when it does
+func (v *chainVerifier) checkBlock(hash []byte, entry blockIndexEntry, prev *BlockHeader) string {
+	header := entry.Header
+	if bytes.Compare(header.Hash, hash) != 0 {
+		return fmt.Sprintf("header is indexed under another hash, it has %x", header.Hash)
+	}
+	if prev == nil && (len(header.PrevBlockHash) != 0 || header.Height != 0) {
+		return "chain doesn't start with a genesis block"
+	}
+	if prev != nil && bytes.Compare(header.PrevBlockHash, prev.Hash) != 0 {
+		return fmt.Sprintf("previous block is %x instead of %x", header.PrevBlockHash, prev.Hash)
+	}
+	if prev != nil && header.Height != prev.Height+1 {
+		return fmt.Sprintf("height doesn't follow %d", prev.Height)
+	}
+
+	// Only the header is left of pruned blocks
+	if entry.Status&blockHaveData == 0 {
+		return ""
+	}
+
+	block, err := v.bc.GetBlock(hash)
+	if err != nil {
+		return fmt.Sprintf("block can't be read: %s", err)
+	}
+	if bytes.Compare(block.Hash, hash) != 0 || block.Height != header.Height ||
+		bytes.Compare(block.PrevBlockHash, header.PrevBlockHash) != 0 {
+		return "block doesn't match its header"
+	}
+
+	pow := NewProofOfWork(&block)
+	powHash := sha256.Sum256(pow.prepareData(block.Nonce))
+	if bytes.Compare(powHash[:], block.Hash) != 0 {
+		return "hash doesn't match the contents of the block"
+	}
+	if !pow.Validate() {
+		return "proof of work doesn't reach the target"
+	}
+
+	if v.level < 1 {
+		return ""
+	}
+
+	if bytes.Compare(block.HashTransactions(), header.TxHash) != 0 {
+		return "Merkle root of the transactions doesn't match the header"
+	}
+	for _, tx := range block.Transactions {
+		if bytes.Compare(tx.ID, tx.UnsignedHash()) != 0 {
+			return fmt.Sprintf("transaction %x has the ID of other contents", tx.ID)
+		}
+	}
+
+	if v.level < 2 {
+		return ""
+	}
+
+	return v.checkTransactions(&block)
+}
+
+// checkTransactions checks the signatures, lock times, spent outputs and coinbase of a block
+func (v *chainVerifier) checkTransactions(block *Block) string {
+	// Miners put the coinbase after the transactions it collects the fees of, it can be anywhere in the block
+	var coinbase *Transaction
+	for _, tx := range block.Transactions {
+		if !tx.IsCoinbase() {
+			continue
+		}
+		if coinbase != nil {
+			return fmt.Sprintf("transaction %x is a second coinbase", tx.ID)
+		}
+		coinbase = tx
+	}
+	if coinbase == nil {
+		return "block has no coinbase"
+	}
+
+	fees := 0
+	for _, tx := range block.Transactions {
+		if tx == coinbase && !v.bc.VerifyTransaction(tx) {
+			return fmt.Sprintf("coinbase %x has an invalid output", tx.ID)
+		}
+		if !v.bc.IsTransactionFinal(tx, block.Height, block.Timestamp) {
+			return fmt.Sprintf("transaction %x isn't final", tx.ID)
+		}
+
+		if tx != coinbase {
+			in := 0
+			for _, vin := range tx.Vin {
+				if !v.seen[hex.EncodeToString(vin.Txid)] {
+					return fmt.Sprintf("transaction %x spends %x, which isn't earlier in the chain", tx.ID, vin.Txid)
+				}
+
+				outpoint := fmt.Sprintf("%x:%d", vin.Txid, vin.Vout)
+				if v.spent[outpoint] {
+					return fmt.Sprintf("transaction %x spends output %d of %x again", tx.ID, vin.Vout, vin.Txid)
+				}
+				v.spent[outpoint] = true
+
+				prevTx, err := v.bc.FindTransaction(vin.Txid)
+				if err != nil {
+					return fmt.Sprintf("transaction %x spends %x, which can't be read: %s", tx.ID, vin.Txid, err)
+				}
+				if vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+					return fmt.Sprintf("transaction %x spends output %d of %x, which doesn't exist", tx.ID, vin.Vout, vin.Txid)
+				}
+				in += prevTx.Vout[vin.Vout].Value
+			}
+
+			if !v.bc.VerifyTransaction(tx) {
+				return fmt.Sprintf("transaction %x has an invalid signature", tx.ID)
+			}
+
+			out := 0
+			for _, vout := range tx.Vout {
+				out += vout.Value
+			}
+			if out > in {
+				return fmt.Sprintf("transaction %x spends %d but its inputs are worth %d", tx.ID, out, in)
+			}
+			fees += in - out
+		}
+
+		v.seen[hex.EncodeToString(tx.ID)] = true
+	}
+
+	reward := 0
+	for _, vout := range coinbase.Vout {
+		reward += vout.Value
+	}
+	if reward > subsidy+fees {
+		return fmt.Sprintf("coinbase pays %d, more than the subsidy and fees of %d", reward, subsidy+fees)
+	}
+
+	return ""
+}
+
+// checkUTXOSet compares the chainstate bucket to the UTXO set recomputed from the blocks
+func (v *chainVerifier) checkUTXOSet() string {
+	stored, err := UTXOSet{v.bc}.Snapshot()
+	if err != nil {
+		return err.Error()
+	}
+	if bytes.Compare(stored.Tip, v.bc.tip) != 0 {
+		return fmt.Sprintf("UTXO set is up to date with block %x instead of the tip", stored.Tip)
+	}
+
+	expected := sortedUTXO(v.bc.FindUTXO())
+	for i := 0; i < len(expected) || i < len(stored.UTXO); i++ {
+		if i == len(stored.UTXO) || i < len(expected) && bytes.Compare(expected[i].Key, stored.UTXO[i].Key) < 0 {
+			txID, vout := splitOutpointKey(expected[i].Key)
+			return fmt.Sprintf("output %d of transaction %x is missing from the UTXO set", vout, txID)
+		}
+		txID, vout := splitOutpointKey(stored.UTXO[i].Key)
+		if i == len(expected) || bytes.Compare(expected[i].Key, stored.UTXO[i].Key) > 0 {
+			return fmt.Sprintf("UTXO set has output %d of transaction %x, which is spent or doesn't exist", vout, txID)
+		}
+		if bytes.Compare(expected[i].Entry.Serialize(), stored.UTXO[i].Entry.Serialize()) != 0 {
+			return fmt.Sprintf("UTXO set has the wrong output %d of transaction %x", vout, txID)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyChain(t *testing.T) {
+	sender := NewWallet()
+	bc := newTestBlockchain(t, sender.Address())
+	UTXOSet := UTXOSet{bc}
+	UTXOSet.Reindex()
+
+	selector, err := GetCoinSelector(defaultCoinSelection)
+	assert.Nil(t, err)
+	tx := NewUTXOTransaction([]*Wallet{sender}, []Recipient{{NewWallet().Address(), 4}}, sender.Address(), selector, 0, sequenceFinal, &UTXOSet)
+	block := bc.MineBlock([]*Transaction{NewCoinbaseTX(sender.Address(), ""), tx})
+	UTXOSet.Update(block)
+
+	for level := 0; level <= maxVerifyLevel; level++ {
+		checked, err := bc.VerifyChain(level)
+		assert.Nil(t, err, "Level %d", level)
+		assert.Equal(t, 2, checked)
+	}
+
+	// A chainstate entry goes missing
+	err = bc.db.Update(func(dbTx StoreTx) error {
+		return dbTx.Bucket([]byte(utxoBucket)).Delete(outpointKey(tx.ID, 0))
+	})
+	assert.Nil(t, err)
+	_, err = bc.VerifyChain(2)
+	assert.Nil(t, err, "Level 2 doesn't look at the UTXO set")
+	_, err = bc.VerifyChain(3)
+	assert.ErrorContains(t, err, "output 0 of transaction")
+
+	// The coinbase of the block pays itself more, the index is rewritten to match it
+	block.Transactions[0].Vout[0].Value = 1000
+	err = bc.db.Update(func(dbTx StoreTx) error {
+		return bc.writeBlock(dbTx, block)
+	})
+	assert.Nil(t, err)
+	checked, err := bc.VerifyChain(0)
+	assert.Equal(t, 1, checked, "The genesis block is fine")
+	assert.ErrorContains(t, err, "hash doesn't match the contents of the block")
+}
+
+func TestVerifyChainCoinbaseLast(t *testing.T) {
+	sender := NewWallet()
+	bc := newTestBlockchain(t, sender.Address())
+	UTXOSet := UTXOSet{bc}
+	UTXOSet.Reindex()
+
+	// The miner of the node puts the coinbase after the transactions of the mempool
+	selector, err := GetCoinSelector(defaultCoinSelection)
+	assert.Nil(t, err)
+	tx := NewUTXOTransaction([]*Wallet{sender}, []Recipient{{NewWallet().Address(), 4}}, sender.Address(), selector, 0, sequenceFinal, &UTXOSet)
+	block := bc.MineBlock([]*Transaction{tx, NewCoinbaseTX(sender.Address(), "")})
+	UTXOSet.Update(block)
+
+	checked, err := bc.VerifyChain(maxVerifyLevel)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, checked)
+
+	bc.MineBlock([]*Transaction{NewCoinbaseTX(sender.Address(), ""), NewCoinbaseTX(sender.Address(), "")})
+	_, err = bc.VerifyChain(2)
+	assert.ErrorContains(t, err, "second coinbase")
+}
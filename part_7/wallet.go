@@ -6,39 +6,129 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"fmt"
 	"log"
+	"math/big"
 
 	"golang.org/x/crypto/ripemd160"
 )
 
-const version = byte(0x00)
 const addressChecksumLen = 4
 
 // Wallet stores private and public keys
 type Wallet struct {
 	PrivateKey ecdsa.PrivateKey
 	PublicKey  []byte
+	// Path is the derivation path of keys derived from the HD seed of Wallets
+	Path string
+	// Encoding is how the address of the wallet is written, Base58Check or Bech32
+	Encoding int
 }
 
 // NewWallet creates and returns a Wallet
 func NewWallet() *Wallet {
 	private, public := newKeyPair()
-	wallet := Wallet{private, public}
+	wallet := Wallet{private, public, "", base58Encoding}
 
 	return &wallet
 }
 
-// GetAddress returns wallet address
-func (w Wallet) GetAddress() []byte {
-	pubKeyHash := HashPubKey(w.PublicKey)
+// NewHDWallet creates a Wallet from a key derived at path
+func NewHDWallet(key *ExtendedKey, path string) *Wallet {
+	private := key.PrivateKey()
+	public := encodePubKey(private.PublicKey)
+	wallet := Wallet{private, public, path, base58Encoding}
+
+	return &wallet
+}
+
+// NewWalletFromPrivateKey creates a Wallet from a private key in wallet import format
+func NewWalletFromPrivateKey(wif string) (*Wallet, error) {
+	keyVersion, key, err := base58CheckDecode(wif)
+	if err != nil {
+		return nil, fmt.Errorf("Private key is not valid: %s", err)
+	}
+	if keyVersion != activeNetwork.PrivateKeyVersion {
+		return nil, fmt.Errorf("Not a %s private key", activeNetwork.Name)
+	}
+	if len(key) != 32 {
+		return nil, errors.New("Private key has invalid length")
+	}
+
+	if !isValidPrivateKey(key) {
+		return nil, errors.New("Private key is out of range")
+	}
+
+	private := privateKeyFromBytes(key)
+	public := encodePubKey(private.PublicKey)
+	wallet := Wallet{private, public, "", base58Encoding}
+
+	return &wallet, nil
+}
+
+// ExportPrivateKey returns the private key in wallet import format: Base58Check of the network's private key version and the key
+func (w Wallet) ExportPrivateKey() string {
+	return base58CheckEncode(activeNetwork.PrivateKeyVersion, paddedKey(w.PrivateKey.D.Bytes()))
+}
+
+// walletData is the stored form of a Wallet, the private key is kept as its scalar
+type walletData struct {
+	D         []byte
+	PublicKey []byte
+	Path      string
+	Encoding  int
+}
+
+// GobEncode encodes the Wallet without the curve of its private key, which gob can't encode
+func (w Wallet) GobEncode() ([]byte, error) {
+	var buff bytes.Buffer
+
+	var d []byte
+	if !w.IsLocked() {
+		d = w.PrivateKey.D.Bytes()
+	}
+
+	enc := gob.NewEncoder(&buff)
+	err := enc.Encode(walletData{d, w.PublicKey, w.Path, w.Encoding})
+
+	return buff.Bytes(), err
+}
+
+// GobDecode decodes a Wallet encoded by GobEncode
+func (w *Wallet) GobDecode(data []byte) error {
+	var wd walletData
 
-	versionedPayload := append([]byte{version}, pubKeyHash...)
-	checksum := checksum(versionedPayload)
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	err := dec.Decode(&wd)
+	if err != nil {
+		return err
+	}
 
-	fullPayload := append(versionedPayload, checksum...)
-	address := Base58Encode(fullPayload)
+	if len(wd.D) > 0 {
+		w.PrivateKey = privateKeyFromBytes(wd.D)
+	}
+	w.PublicKey = wd.PublicKey
+	w.Path = wd.Path
+	w.Encoding = wd.Encoding
 
-	return address
+	return nil
+}
+
+// IsLocked checks whether the private key is unavailable because the wallet file is encrypted and locked
+func (w Wallet) IsLocked() bool {
+	return w.PrivateKey.D == nil
+}
+
+// GetAddress returns wallet address
+func (w Wallet) GetAddress() []byte {
+	return []byte(w.Address().String())
+}
+
+// Address returns the decoded address of the wallet
+func (w Wallet) Address() Address {
+	return NewPubKeyHashAddress(HashPubKey(w.PublicKey)).WithEncoding(w.Encoding)
 }
 
 // HashPubKey hashes public key
@@ -55,17 +145,6 @@ func HashPubKey(pubKey []byte) []byte {
 	return publicRIPEMD160
 }
 
-// ValidateAddress check if address if valid
-func ValidateAddress(address string) bool {
-	pubKeyHash := Base58Decode([]byte(address))
-	actualChecksum := pubKeyHash[len(pubKeyHash)-addressChecksumLen:]
-	version := pubKeyHash[0]
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
-	targetChecksum := checksum(append([]byte{version}, pubKeyHash...))
-
-	return bytes.Compare(actualChecksum, targetChecksum) == 0
-}
-
 // Checksum generates a checksum for a public key
 func checksum(payload []byte) []byte {
 	firstSHA := sha256.Sum256(payload)
@@ -74,13 +153,47 @@ func checksum(payload []byte) []byte {
 	return secondSHA[:addressChecksumLen]
 }
 
+// privateKeyFromBytes rebuilds a P256 private key from its scalar
+func privateKeyFromBytes(d []byte) ecdsa.PrivateKey {
+	curve := elliptic.P256()
+	private := ecdsa.PrivateKey{}
+	private.Curve = curve
+	private.D = new(big.Int).SetBytes(d)
+	private.X, private.Y = curve.ScalarBaseMult(paddedKey(d))
+
+	return private
+}
+
+// encodePubKey concatenates X and Y padded to the same width, so they can be split in the middle
+func encodePubKey(pubKey ecdsa.PublicKey) []byte {
+	size := (pubKey.Curve.Params().BitSize + 7) / 8
+	encoded := make([]byte, 2*size)
+	pubKey.X.FillBytes(encoded[:size])
+	pubKey.Y.FillBytes(encoded[size:])
+
+	return encoded
+}
+
+// isValidPubKey checks that pubKey is a P256 point encoded by encodePubKey
+func isValidPubKey(pubKey []byte) bool {
+	curve := elliptic.P256()
+	size := (curve.Params().BitSize + 7) / 8
+	if len(pubKey) != 2*size {
+		return false
+	}
+	x := new(big.Int).SetBytes(pubKey[:size])
+	y := new(big.Int).SetBytes(pubKey[size:])
+
+	return curve.IsOnCurve(x, y)
+}
+
 func newKeyPair() (ecdsa.PrivateKey, []byte) {
 	curve := elliptic.P256()
 	private, err := ecdsa.GenerateKey(curve, rand.Reader)
 	if err != nil {
 		log.Panic(err)
 	}
-	pubKey := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)
+	pubKey := encodePubKey(private.PublicKey)
 
 	return *private, pubKey
 }
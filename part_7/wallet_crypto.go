@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// scrypt parameters used to derive the wallet key from a passphrase
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	walletKeyLen = 32
+)
+
+// WalletEncryption holds the private keys and seed of an encrypted wallet, sealed with AES-GCM
+// under a key derived from the passphrase with scrypt
+type WalletEncryption struct {
+	Salt       []byte
+	N, R, P    int
+	Ciphertext []byte
+}
+
+// walletSecrets is what gets encrypted: private key scalars by address and the HD seed
+type walletSecrets struct {
+	Keys map[string][]byte
+	Seed []byte
+}
+
+// EncryptWallet encrypts the private keys of Wallets with a passphrase.
+// The wallet stays unlocked until Lock is called
+func (ws *Wallets) EncryptWallet(passphrase string) error {
+	if ws.Encryption != nil {
+		return errors.New("Wallet is already encrypted")
+	}
+	if passphrase == "" {
+		return errors.New("Passphrase can't be empty")
+	}
+
+	salt := make([]byte, 16)
+	_, err := rand.Read(salt)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	encryption := &WalletEncryption{salt, scryptN, scryptR, scryptP, nil}
+	key, err := encryption.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	ws.Encryption = encryption
+	ws.key = key
+	ws.seal()
+
+	return nil
+}
+
+// Unlock decrypts the private keys of an encrypted wallet
+func (ws *Wallets) Unlock(passphrase string) error {
+	if ws.Encryption == nil {
+		return errors.New("Wallet is not encrypted")
+	}
+
+	key, err := ws.Encryption.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := decryptData(key, ws.Encryption.Ciphertext)
+	if err != nil {
+		return errors.New("The wallet passphrase entered was incorrect")
+	}
+
+	var secrets walletSecrets
+	dec := gob.NewDecoder(bytes.NewReader(plaintext))
+	err = dec.Decode(&secrets)
+	if err != nil {
+		return err
+	}
+
+	for address, wallet := range ws.Wallets {
+		if d, ok := secrets.Keys[address]; ok {
+			wallet.PrivateKey = privateKeyFromBytes(d)
+		}
+	}
+	ws.Seed = secrets.Seed
+	ws.key = key
+
+	return nil
+}
+
+// Lock removes the decrypted private keys of an encrypted wallet from memory
+func (ws *Wallets) Lock() {
+	if ws.Encryption == nil {
+		return
+	}
+
+	for _, wallet := range ws.Wallets {
+		wallet.PrivateKey = ecdsa.PrivateKey{}
+	}
+	ws.Seed = nil
+	ws.key = nil
+}
+
+// IsLocked checks whether the wallet is encrypted and its private keys are not available
+func (ws Wallets) IsLocked() bool {
+	return ws.Encryption != nil && ws.key == nil
+}
+
+// seal encrypts the current private keys and seed into Encryption
+func (ws *Wallets) seal() {
+	secrets := walletSecrets{make(map[string][]byte), ws.Seed}
+	for address, wallet := range ws.Wallets {
+		if !wallet.IsLocked() {
+			secrets.Keys[address] = wallet.PrivateKey.D.Bytes()
+		}
+	}
+
+	var plaintext bytes.Buffer
+	enc := gob.NewEncoder(&plaintext)
+	err := enc.Encode(secrets)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	ws.Encryption.Ciphertext = encryptData(ws.key, plaintext.Bytes())
+}
+
+// stripped returns a copy of Wallets without private keys and seed, to be written to disk
+func (ws Wallets) stripped() Wallets {
+	if !ws.IsLocked() {
+		ws.seal()
+	}
+
+	wallets := make(map[string]*Wallet)
+	for address, wallet := range ws.Wallets {
+		wallets[address] = &Wallet{ecdsa.PrivateKey{}, wallet.PublicKey, wallet.Path, wallet.Encoding}
+	}
+	ws.Wallets = wallets
+	ws.Seed = nil
+
+	return ws
+}
+
+func (e *WalletEncryption) deriveKey(passphrase string) ([]byte, error) {
+	key, err := scrypt.Key([]byte(passphrase), e.Salt, e.N, e.R, e.P, walletKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("Can't derive the wallet key: %s", err)
+	}
+
+	return key, nil
+}
+
+// encryptData seals plaintext with AES-GCM and prepends the random nonce
+func encryptData(key, plaintext []byte) []byte {
+	gcm := newGCM(key)
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil)
+}
+
+// decryptData opens data sealed by encryptData
+func decryptData(key, data []byte) ([]byte, error) {
+	gcm := newGCM(key)
+
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("Ciphertext is too short")
+	}
+	nonce := data[:gcm.NonceSize()]
+
+	return gcm.Open(nil, nonce, data[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) cipher.AEAD {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return gcm
+}
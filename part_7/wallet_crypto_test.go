@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletEncryption(t *testing.T) {
+	dir, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(dir)
+
+	wallets, _ := NewWallets("test")
+	address := wallets.CreateWallet(base58Encoding)
+	original := wallets.GetWallet(address)
+
+	// A wallet file saved before encryption may be readable by others
+	assert.Nil(t, ioutil.WriteFile("wallet_test.dat", nil, 0644))
+	assert.Nil(t, wallets.EncryptWallet("secret"))
+	wallets.SaveToFile("test")
+
+	info, err := os.Stat("wallet_test.dat")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	content, err := ioutil.ReadFile("wallet_test.dat")
+	assert.Nil(t, err)
+	assert.False(t, strings.Contains(string(content), string(original.PrivateKey.D.Bytes())), "Private key is not stored in clear")
+
+	loaded, err := NewWallets("test")
+	assert.Nil(t, err)
+	assert.True(t, loaded.IsLocked())
+	assert.True(t, loaded.GetWallet(address).IsLocked(), "Locked wallet has no private keys")
+	assert.Panics(t, func() { loaded.CreateWallet(base58Encoding) }, "Locked wallet can't create keys")
+
+	assert.NotNil(t, loaded.Unlock("wrong"))
+	assert.Nil(t, loaded.Unlock("secret"))
+	assert.Equal(t, original.PrivateKey.D, loaded.GetWallet(address).PrivateKey.D)
+
+	loaded.Lock()
+	assert.True(t, loaded.IsLocked())
+	assert.True(t, loaded.GetWallet(address).IsLocked())
+}
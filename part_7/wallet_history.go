@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"log"
+	"sort"
+)
+
+const walletTxBucket = "wallettxs"
+
+// WalletTx is a transaction touching the wallet and the block it was mined in
+type WalletTx struct {
+	Tx        Transaction
+	BlockHash []byte
+	Height    int
+	Index     int
+	Timestamp int64
+	// Deltas is the change of balance of every wallet address the transaction touches
+	Deltas         map[string]int
+	Fee            int
+	Counterparties []string
+}
+
+// Amount returns the change of the wallet balance made by the transaction
+func (wtx WalletTx) Amount() int {
+	amount := 0
+	for _, delta := range wtx.Deltas {
+		amount += delta
+	}
+
+	return amount
+}
+
+// Serialize serializes the wallet transaction
+func (wtx WalletTx) Serialize() []byte {
+	var encoded bytes.Buffer
+
+	enc := gob.NewEncoder(&encoded)
+	err := enc.Encode(wtx)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return encoded.Bytes()
+}
+
+// DeserializeWalletTx deserializes a wallet transaction
+func DeserializeWalletTx(data []byte) WalletTx {
+	var wtx WalletTx
+
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	err := dec.Decode(&wtx)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return wtx
+}
+
+// WalletHistory keeps the transactions of Wallets in the node db, following the blocks of the chain
+type WalletHistory struct {
+	Blockchain *Blockchain
+	Wallets    *Wallets
+}
+
+// Sync brings the history up to the tip of the chain, disconnecting blocks that left the main chain
+func (h WalletHistory) Sync() {
+	syncChainIndex(h.Blockchain, h)
+}
+
+// Rescan rebuilds the history from the genesis block, needed when addresses with past transactions are added
+func (h WalletHistory) Rescan() {
+	db := h.Blockchain.db
+	bucketName := []byte(walletTxBucket)
+
+	err := db.Update(func(tx StoreTx) error {
+		err := tx.DeleteBucket(bucketName)
+		if err != nil && err != errBucketNotFound {
+			log.Panic(err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	h.Sync()
+}
+
+// ConnectBlock records the wallet transactions of a block added to the main chain
+func (h WalletHistory) ConnectBlock(block *Block) {
+	var records []WalletTx
+
+	for i, tx := range block.Transactions {
+		wtx, ok := h.newWalletTx(tx, records)
+		if !ok {
+			continue
+		}
+
+		wtx.BlockHash = block.Hash
+		wtx.Height = block.Height
+		wtx.Index = i
+		wtx.Timestamp = block.Timestamp
+		records = append(records, wtx)
+	}
+
+	err := h.Blockchain.db.Update(func(tx StoreTx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(walletTxBucket))
+		if err != nil {
+			log.Panic(err)
+		}
+
+		for _, wtx := range records {
+			err = b.Put(wtx.Tx.ID, wtx.Serialize())
+			if err != nil {
+				log.Panic(err)
+			}
+		}
+
+		return b.Put([]byte("l"), block.Hash)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// DisconnectBlock removes the wallet transactions of a block that left the main chain
+func (h WalletHistory) DisconnectBlock(block *Block) {
+	err := h.Blockchain.db.Update(func(tx StoreTx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(walletTxBucket))
+		if err != nil {
+			log.Panic(err)
+		}
+
+		for _, transaction := range block.Transactions {
+			data := b.Get(transaction.ID)
+			if data == nil || bytes.Compare(DeserializeWalletTx(data).BlockHash, block.Hash) != 0 {
+				continue
+			}
+
+			err = b.Delete(transaction.ID)
+			if err != nil {
+				log.Panic(err)
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			return b.Delete([]byte("l"))
+		}
+
+		return b.Put([]byte("l"), block.PrevBlockHash)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// FindTransaction returns a wallet transaction by its ID
+func (h WalletHistory) FindTransaction(ID []byte) (WalletTx, bool) {
+	var wtx WalletTx
+	found := false
+
+	err := h.Blockchain.db.View(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(walletTxBucket))
+		if b == nil {
+			return nil
+		}
+
+		data := b.Get(ID)
+		if data != nil {
+			wtx = DeserializeWalletTx(data)
+			found = true
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return wtx, found
+}
+
+// Transactions returns the wallet transactions in chain order, only the ones touching address unless it's empty
+func (h WalletHistory) Transactions(address string) []WalletTx {
+	var wtxs []WalletTx
+
+	err := h.Blockchain.db.View(func(tx StoreTx) error {
+		b := tx.Bucket([]byte(walletTxBucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			if bytes.Compare(k, []byte("l")) == 0 {
+				return nil
+			}
+
+			wtx := DeserializeWalletTx(v)
+			if _, ok := wtx.Deltas[address]; ok || address == "" {
+				wtxs = append(wtxs, wtx)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	sort.Slice(wtxs, func(i, j int) bool {
+		if wtxs[i].Height != wtxs[j].Height {
+			return wtxs[i].Height < wtxs[j].Height
+		}
+
+		return wtxs[i].Index < wtxs[j].Index
+	})
+
+	return wtxs
+}
+
+// newWalletTx works out what tx means to the wallet, it reports false when tx doesn't touch the wallet.
+// earlier holds the wallet transactions of the block that precede tx
+func (h WalletHistory) newWalletTx(tx *Transaction, earlier []WalletTx) (WalletTx, bool) {
+	wtx := WalletTx{Tx: *tx, Deltas: make(map[string]int)}
+
+	for _, out := range tx.Vout {
+		if address, ok := h.Wallets.FindAddress(out.Address()); ok && !out.IsUnspendable() {
+			wtx.Deltas[address] += out.Value
+		}
+	}
+
+	if !tx.IsCoinbase() {
+		// An input can only spend the wallet's coins if the wallet has seen the transaction paying them
+		spendsWallet := false
+		for _, vin := range tx.Vin {
+			if h.isWalletOutput(vin.Txid, vin.Vout, earlier) {
+				spendsWallet = true
+				break
+			}
+		}
+		if !spendsWallet && len(wtx.Deltas) == 0 {
+			return wtx, false
+		}
+
+		prevTXs := h.Blockchain.findPrevTransactions(tx)
+		inputs := 0
+		for _, vin := range tx.Vin {
+			prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
+			prevOut := prevTx.Vout[vin.Vout]
+			inputs += prevOut.Value
+
+			if address, ok := h.Wallets.FindAddress(prevOut.Address()); ok {
+				wtx.Deltas[address] -= prevOut.Value
+			} else {
+				wtx.addCounterparty(prevOut.Address().String())
+			}
+		}
+
+		outputs := 0
+		for _, out := range tx.Vout {
+			outputs += out.Value
+		}
+		wtx.Fee = inputs - outputs
+	} else if len(wtx.Deltas) == 0 {
+		return wtx, false
+	}
+
+	// Payments out of the wallet go to the addresses of the outputs it doesn't own
+	if wtx.Amount() < 0 {
+		wtx.Counterparties = nil
+		for _, out := range tx.Vout {
+			if !out.IsUnspendable() && !h.Wallets.IsMine(out.Address()) {
+				wtx.addCounterparty(out.Address().String())
+			}
+		}
+	}
+
+	return wtx, true
+}
+
+// isWalletOutput checks whether output vout of transaction txID was paid to the wallet
+func (h WalletHistory) isWalletOutput(txID []byte, vout int, earlier []WalletTx) bool {
+	for _, wtx := range earlier {
+		if bytes.Compare(wtx.Tx.ID, txID) == 0 {
+			return vout < len(wtx.Tx.Vout) && h.Wallets.IsMine(wtx.Tx.Vout[vout].Address())
+		}
+	}
+
+	wtx, ok := h.FindTransaction(txID)
+
+	return ok && vout < len(wtx.Tx.Vout) && h.Wallets.IsMine(wtx.Tx.Vout[vout].Address())
+}
+
+func (h WalletHistory) syncedHash() []byte {
+	return indexTip(h.Blockchain.db, walletTxBucket)
+}
+
+func (wtx *WalletTx) addCounterparty(address string) {
+	for _, counterparty := range wtx.Counterparties {
+		if counterparty == address {
+			return
+		}
+	}
+
+	wtx.Counterparties = append(wtx.Counterparties, address)
+}
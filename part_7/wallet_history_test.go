@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletHistorySync(t *testing.T) {
+	wallets := &Wallets{Wallets: make(map[string]*Wallet), Scripts: make(map[string]*MultisigScript), Watched: make(map[string][]byte)}
+	address := wallets.CreateWallet(base58Encoding)
+	mine := wallets.Wallets[address]
+	other := NewWallet()
+
+	bc := newTestBlockchain(t, mine.Address())
+	genesis := bc.tip
+	UTXOSet := UTXOSet{bc}
+	UTXOSet.Reindex()
+
+	selector, _ := GetCoinSelector(defaultCoinSelection)
+	payment := NewUTXOTransaction([]*Wallet{mine}, []Recipient{{other.Address(), 4}}, mine.Address(), selector, 0, sequenceFinal, &UTXOSet)
+	block := bc.MineBlock([]*Transaction{NewCoinbaseTX(other.Address(), ""), payment})
+	UTXOSet.Update(block)
+
+	history := WalletHistory{bc, wallets}
+	history.Sync()
+	wtxs := history.Transactions("")
+	assert.Equal(t, 2, len(wtxs), "The coinbase of the other address isn't recorded")
+	assert.Equal(t, subsidy, wtxs[0].Amount())
+	assert.Equal(t, payment.ID, wtxs[1].Tx.ID)
+	assert.Equal(t, -4, wtxs[1].Amount())
+	assert.Equal(t, 0, wtxs[1].Fee)
+	assert.Equal(t, []string{other.Address().String()}, wtxs[1].Counterparties)
+	assert.Equal(t, block.Hash, wtxs[1].BlockHash)
+	assert.Equal(t, wtxs, history.Transactions(address))
+	assert.Empty(t, history.Transactions(other.Address().String()))
+
+	// A longer branch from the genesis block takes over and the payment leaves the chain
+	side := NewBlock([]*Transaction{NewCoinbaseTX(other.Address(), "side")}, genesis, 1)
+	bc.AddBlock(side)
+	sideTip := NewBlock([]*Transaction{NewCoinbaseTX(other.Address(), "")}, side.Hash, 2)
+	bc.AddBlock(sideTip)
+
+	history.Sync()
+	assert.Equal(t, sideTip.Hash, history.syncedHash())
+	wtxs = history.Transactions("")
+	assert.Equal(t, 1, len(wtxs))
+	assert.Equal(t, genesis, wtxs[0].BlockHash)
+	_, found := history.FindTransaction(payment.ID)
+	assert.False(t, found)
+
+	// Disconnecting the whole chain empties the history
+	history.DisconnectBlock(sideTip)
+	history.DisconnectBlock(side)
+	genesisBlock, err := bc.GetBlock(genesis)
+	assert.Nil(t, err)
+	history.DisconnectBlock(&genesisBlock)
+	assert.Nil(t, history.syncedHash())
+	assert.Empty(t, history.Transactions(""))
+}
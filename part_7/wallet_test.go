@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateKeyImportFormat(t *testing.T) {
+	wallet := NewWallet()
+	wif := wallet.ExportPrivateKey()
+
+	imported, err := NewWalletFromPrivateKey(wif)
+	assert.Nil(t, err)
+	assert.Equal(t, wallet.GetAddress(), imported.GetAddress(), "Imported key has the same address")
+	assert.Equal(t, 0, wallet.PrivateKey.D.Cmp(imported.PrivateKey.D))
+
+	corrupted := []byte(wif)
+	if corrupted[len(corrupted)-1] == '2' {
+		corrupted[len(corrupted)-1] = '3'
+	} else {
+		corrupted[len(corrupted)-1] = '2'
+	}
+	_, err = NewWalletFromPrivateKey(string(corrupted))
+	assert.NotNil(t, err, "Checksum catches typos")
+
+	_, err = NewWalletFromPrivateKey(string(wallet.GetAddress()))
+	assert.NotNil(t, err, "Addresses aren't private keys")
+}
+
+func TestWatchOnlyImport(t *testing.T) {
+	wallets := Wallets{Wallets: make(map[string]*Wallet), Watched: make(map[string][]byte)}
+	wallet := NewWallet()
+	address := string(wallet.GetAddress())
+
+	_, err := wallets.ImportPubKey(wallet.PublicKey[1:])
+	assert.NotNil(t, err, "Public keys have a fixed width")
+	offCurve := append([]byte{}, wallet.PublicKey...)
+	offCurve[len(offCurve)-1] ^= 1
+	_, err = wallets.ImportPubKey(offCurve)
+	assert.NotNil(t, err, "Public keys are points of the curve")
+	assert.Empty(t, wallets.Watched)
+
+	watched, err := wallets.ImportPubKey(wallet.PublicKey)
+	assert.Nil(t, err)
+	assert.Equal(t, address, watched)
+	assert.True(t, wallets.IsMine(wallet.Address()), "Watch-only addresses belong to the wallet")
+	assert.NotNil(t, wallets.ImportAddress(address), "Address is imported once")
+
+	_, err = wallets.DumpPrivateKey(address)
+	assert.NotNil(t, err, "Watch-only addresses have no private key")
+
+	imported, err := wallets.ImportPrivateKey(wallet.ExportPrivateKey())
+	assert.Nil(t, err)
+	assert.Equal(t, address, imported)
+	assert.NotContains(t, wallets.Watched, address, "Importing the key makes the address spendable")
+
+	wif, err := wallets.DumpPrivateKey(address)
+	assert.Nil(t, err)
+	assert.Equal(t, wallet.ExportPrivateKey(), wif)
+}
@@ -2,8 +2,8 @@ package main
 
 import (
 	"bytes"
-	"crypto/elliptic"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -12,24 +12,59 @@ import (
 
 const walletFile = "wallet_%s.dat"
 
+// Keys derived from the HD seed live under hdAccountPath/chain/index
+const hdAccountPath = "m/44'/0'/0'"
+const (
+	receiveChain = 0
+	changeChain  = 1
+)
+
+// gapLimit is the number of unused addresses in a row after which restore stops looking
+const gapLimit = 20
+
 // Wallets stores a collection of wallets
 type Wallets struct {
 	Wallets map[string]*Wallet
+	Scripts map[string]*MultisigScript
+	// Watched are watch-only addresses and their public keys, when known
+	Watched map[string][]byte
+	Seed    []byte
+	// NextIndex is the next index to derive on the receive and change chains
+	NextIndex [2]uint32
+	// Network is the name of the network the addresses of the wallet are on
+	Network string
+	// Encryption is set once the wallet is encrypted, private keys and Seed are then only kept in memory
+	Encryption *WalletEncryption
+	// key decrypts Encryption while the wallet is unlocked
+	key []byte
 }
 
 // NewWallets creates Wallets and fills it from a file if it exists
 func NewWallets(nodeID string) (*Wallets, error) {
 	wallets := Wallets{}
 	wallets.Wallets = make(map[string]*Wallet)
+	wallets.Scripts = make(map[string]*MultisigScript)
+	wallets.Watched = make(map[string][]byte)
+	wallets.Network = activeNetwork.Name
 
 	err := wallets.LoadFromFile(nodeID)
 
 	return &wallets, err
 }
 
-// CreateWallet adds a Wallet to Wallets
-func (ws *Wallets) CreateWallet() string {
+// CreateWallet adds a Wallet to Wallets, deriving it from the HD seed when there is one.
+// Its address is written with encoding
+func (ws *Wallets) CreateWallet(encoding int) string {
+	if ws.IsLocked() {
+		log.Panic("ERROR: Wallet is locked")
+	}
+
+	if ws.Seed != nil {
+		return ws.deriveNext(receiveChain, encoding)
+	}
+
 	wallet := NewWallet()
+	wallet.Encoding = encoding
 	address := fmt.Sprintf("%s", wallet.GetAddress())
 
 	ws.Wallets[address] = wallet
@@ -37,6 +72,125 @@ func (ws *Wallets) CreateWallet() string {
 	return address
 }
 
+// CreateChangeWallet adds a fresh address to receive change, from the change chain of an HD wallet
+func (ws *Wallets) CreateChangeWallet(encoding int) string {
+	if ws.IsLocked() {
+		log.Panic("ERROR: Wallet is locked")
+	}
+
+	if ws.Seed != nil {
+		return ws.deriveNext(changeChain, encoding)
+	}
+
+	return ws.CreateWallet(encoding)
+}
+
+// SetSeed makes Wallets derive its keys from an HD seed
+func (ws *Wallets) SetSeed(seed []byte) error {
+	if ws.IsLocked() {
+		return errors.New("Wallet is locked")
+	}
+	if ws.Seed != nil {
+		return errors.New("Wallet already has an HD seed")
+	}
+
+	_, err := NewMasterKey(seed)
+	if err != nil {
+		return err
+	}
+
+	ws.Seed = seed
+	ws.NextIndex = [2]uint32{0, 0}
+
+	return nil
+}
+
+// Discover derives the addresses of both chains that are in use according to isUsed,
+// stopping after gapLimit unused addresses in a row
+func (ws *Wallets) Discover(isUsed func(pubKeyHash []byte) bool) {
+	for _, chain := range []uint32{receiveChain, changeChain} {
+		var found []*Wallet
+		unused := 0
+
+		for index := uint32(0); unused < gapLimit; index++ {
+			// Keys that can't be derived count against the gap, a seed that derives nothing can't loop forever
+			wallet, err := ws.deriveWallet(chain, index)
+			if err != nil {
+				unused++
+				continue
+			}
+
+			found = append(found, wallet)
+			if isUsed(HashPubKey(wallet.PublicKey)) {
+				for _, w := range found {
+					ws.Wallets[fmt.Sprintf("%s", w.GetAddress())] = w
+				}
+				found = nil
+				unused = 0
+				ws.NextIndex[chain] = index + 1
+			} else {
+				unused++
+			}
+		}
+	}
+}
+
+// deriveNext derives the next key of an HD chain and adds it to Wallets
+func (ws *Wallets) deriveNext(chain uint32, encoding int) string {
+	for failed := 0; ; failed++ {
+		index := ws.NextIndex[chain]
+		ws.NextIndex[chain]++
+
+		// Invalid keys are skipped, but gapLimit failures in a row mean the seed derives nothing
+		wallet, err := ws.deriveWallet(chain, index)
+		if err != nil {
+			if failed+1 >= gapLimit {
+				log.Panic(err)
+			}
+			continue
+		}
+		wallet.Encoding = encoding
+
+		address := fmt.Sprintf("%s", wallet.GetAddress())
+		ws.Wallets[address] = wallet
+
+		return address
+	}
+}
+
+// deriveWallet derives the key at index of an HD chain
+func (ws *Wallets) deriveWallet(chain, index uint32) (*Wallet, error) {
+	master, err := NewMasterKey(ws.Seed)
+	if err != nil {
+		return nil, err
+	}
+
+	path := fmt.Sprintf("%s/%d/%d", hdAccountPath, chain, index)
+	key, err := master.Derive(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewHDWallet(key, path), nil
+}
+
+// AddMultisig adds a multisig redeem script to Wallets and returns its address
+func (ws *Wallets) AddMultisig(script *MultisigScript) string {
+	address := fmt.Sprintf("%s", script.GetAddress())
+
+	ws.Scripts[address] = script
+
+	return address
+}
+
+// GetMultisig returns a multisig redeem script by its address, in either encoding
+func (ws Wallets) GetMultisig(address Address) (*MultisigScript, bool) {
+	key, _ := ws.FindAddress(address)
+	script, ok := ws.Scripts[key]
+
+	return script, ok
+}
+
 // GetAddresses returns an array of addresses stored in the wallet file
 func (ws *Wallets) GetAddresses() []string {
 	var addresses []string
@@ -48,6 +202,104 @@ func (ws *Wallets) GetAddresses() []string {
 	return addresses
 }
 
+// FindAddress returns the key address is stored under in Wallets, which may be written in another encoding
+func (ws Wallets) FindAddress(address Address) (string, bool) {
+	for _, encoding := range []int{base58Encoding, bech32Encoding} {
+		key := address.WithEncoding(encoding).String()
+
+		_, isWallet := ws.Wallets[key]
+		_, isScript := ws.Scripts[key]
+		_, isWatched := ws.Watched[key]
+		if isWallet || isScript || isWatched {
+			return key, true
+		}
+	}
+
+	return "", false
+}
+
+// IsMine checks whether address is one of the wallet, multisig or watch-only addresses of Wallets
+func (ws Wallets) IsMine(address Address) bool {
+	_, ok := ws.FindAddress(address)
+
+	return ok
+}
+
+// ImportAddress adds a watch-only address, its balance and history are tracked but it can't be spent from
+func (ws *Wallets) ImportAddress(address string) error {
+	decoded, err := DecodeAddress(address)
+	if err != nil {
+		return err
+	}
+	if ws.IsMine(decoded) {
+		return errors.New("Address is already in the wallet")
+	}
+
+	ws.Watched[address] = nil
+
+	return nil
+}
+
+// ImportPubKey adds the address of a public key as watch-only
+func (ws *Wallets) ImportPubKey(pubKey []byte) (string, error) {
+	if !isValidPubKey(pubKey) {
+		return "", errors.New("Public key is not a P256 point")
+	}
+
+	address := NewPubKeyHashAddress(HashPubKey(pubKey)).String()
+
+	err := ws.ImportAddress(address)
+	if err != nil {
+		return "", err
+	}
+	ws.Watched[address] = pubKey
+
+	return address, nil
+}
+
+// ImportPrivateKey adds a key in wallet import format, a watch-only address of the key becomes spendable
+func (ws *Wallets) ImportPrivateKey(wif string) (string, error) {
+	if ws.IsLocked() {
+		return "", errors.New("Wallet is locked")
+	}
+
+	wallet, err := NewWalletFromPrivateKey(wif)
+	if err != nil {
+		return "", err
+	}
+
+	// A watched address keeps the encoding it was imported with
+	if key, ok := ws.FindAddress(wallet.Address()); ok {
+		if _, watched := ws.Watched[key]; !watched {
+			return "", errors.New("Key is already in the wallet")
+		}
+		decoded, err := DecodeAddress(key)
+		if err != nil {
+			return "", err
+		}
+		wallet.Encoding = decoded.Encoding
+		delete(ws.Watched, key)
+	}
+
+	address := fmt.Sprintf("%s", wallet.GetAddress())
+	ws.Wallets[address] = wallet
+
+	return address, nil
+}
+
+// DumpPrivateKey returns the private key of a wallet address in wallet import format
+func (ws Wallets) DumpPrivateKey(address string) (string, error) {
+	wallet, ok := ws.Wallets[address]
+	if !ok {
+		return "", errors.New("Address is not in the wallet")
+	}
+	if wallet.IsLocked() {
+		return "", errors.New("Wallet is locked")
+	}
+
+	return wallet.ExportPrivateKey(), nil
+}
+
 // GetWallet returns a Wallet by its address
 func (ws Wallets) GetWallet(address string) Wallet {
 	return *ws.Wallets[address]
@@ -66,24 +318,39 @@ func (ws *Wallets) LoadFromFile(nodeID string) error {
 	}
 
 	var wallets Wallets
-	gob.Register(elliptic.P256())
 	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
 	err = decoder.Decode(&wallets)
 	if err != nil {
 		log.Panic(err)
 	}
 
+	// Wallets saved before the network was recorded are taken to be on the active network
+	if wallets.Network != "" && wallets.Network != activeNetwork.Name {
+		log.Panicf("ERROR: The wallet is on %s, this node is on %s. Set NETWORK=%s", wallets.Network, activeNetwork.Name, wallets.Network)
+	}
+
 	ws.Wallets = wallets.Wallets
+	if wallets.Scripts != nil {
+		ws.Scripts = wallets.Scripts
+	}
+	if wallets.Watched != nil {
+		ws.Watched = wallets.Watched
+	}
+	ws.Seed = wallets.Seed
+	ws.NextIndex = wallets.NextIndex
+	ws.Encryption = wallets.Encryption
 
 	return nil
 }
 
-// SaveToFile saves wallets to a file
+// SaveToFile saves wallets to a file, private keys of an encrypted wallet are saved encrypted
 func (ws Wallets) SaveToFile(nodeID string) {
 	var content bytes.Buffer
 	walletFile := fmt.Sprintf(walletFile, nodeID)
 
-	gob.Register(elliptic.P256())
+	if ws.Encryption != nil {
+		ws = ws.stripped()
+	}
 
 	encoder := gob.NewEncoder(&content)
 	err := encoder.Encode(ws)
@@ -91,8 +358,5 @@ func (ws Wallets) SaveToFile(nodeID string) {
 		log.Panic(err)
 	}
 
-	err = ioutil.WriteFile(walletFile, content.Bytes(), 0644)
-	if err != nil {
-		log.Panic(err)
-	}
+	writePrivateFile(walletFile, content.Bytes())
 }